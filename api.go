@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cardInput is the body of requests that add or edit a card. Fields left
+// out of an edit keep their values.
+type cardInput struct {
+	Front       *string   `json:"front"`
+	Back        *string   `json:"back"`
+	Tags        *[]string `json:"tags"`
+	Suspended   *bool     `json:"suspended"`
+	Flagged     *bool     `json:"flagged"`
+	BuriedUntil *string   `json:"buried_until"` // 2006/01/02, or "" to unbury
+}
+
+// sectionMarkers are the lines that start a section of a .flsh file, which
+// card text must not contain
+var sectionMarkers = []string{"###", "&&&", "@@@", "***", "!FRONT", "!BACK", "!TAGS", "!REVIEWED", "!STATE"}
+
+// checkCardText reports why text cannot be the front or back of a card.
+func checkCardText(field, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("%s must not be empty", field)
+	}
+	for _, line := range strings.Split(text, "\n") {
+		for _, marker := range sectionMarkers {
+			if strings.TrimSpace(line) == marker {
+				return fmt.Errorf("%s must not contain a %s line", field, marker)
+			}
+		}
+	}
+	return nil
+}
+
+// apply checks the input and copies its fields to card.
+func (in cardInput) apply(card *Flashcard) error {
+	if in.Front != nil {
+		if err := checkCardText("front", *in.Front); err != nil {
+			return httpError{http.StatusBadRequest, err.Error()}
+		}
+		card.Front = *in.Front
+	}
+	if in.Back != nil {
+		if err := checkCardText("back", *in.Back); err != nil {
+			return httpError{http.StatusBadRequest, err.Error()}
+		}
+		card.Back = *in.Back
+	}
+	if in.Tags != nil {
+		var tags []string
+		for _, tag := range *in.Tags {
+			if tag == "" || strings.ContainsAny(tag, " \t\n") {
+				return httpError{http.StatusBadRequest, fmt.Sprintf("invalid tag %q", tag)}
+			}
+			tags = append(tags, tag)
+		}
+		card.Tags = tags
+	}
+	if in.Suspended != nil {
+		card.Suspended = *in.Suspended
+	}
+	if in.Flagged != nil {
+		card.Flagged = *in.Flagged
+	}
+	if in.BuriedUntil != nil {
+		if *in.BuriedUntil != "" {
+			if _, err := time.Parse("2006/01/02", *in.BuriedUntil); err != nil {
+				return httpError{http.StatusBadRequest, fmt.Sprintf("invalid buried_until %q, expected YYYY/MM/DD", *in.BuriedUntil)}
+			}
+		}
+		card.BuriedUntil = *in.BuriedUntil
+	}
+	return nil
+}
+
+func (s *server) listCards(w http.ResponseWriter, r *http.Request) {
+	ff, err := s.readDeck(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	cards := []cardInfo{}
+	for i := range ff.Cards {
+		cards = append(cards, newCardInfo(i+1, &ff.Cards[i]))
+	}
+	writeJSON(w, http.StatusOK, cards)
+}
+
+func (s *server) getCard(w http.ResponseWriter, r *http.Request) {
+	ff, err := s.readDeck(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	idx, err := cardNumber(r, ff)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	info := newCardInfo(idx+1, &ff.Cards[idx])
+	w.Header().Set("ETag", info.ETag)
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *server) addCard(w http.ResponseWriter, r *http.Request) {
+	var in cardInput
+	if err := readBody(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+	if in.Front == nil || in.Back == nil {
+		writeError(w, httpError{http.StatusBadRequest, `"front" and "back" are required`})
+		return
+	}
+
+	var added cardInfo
+	path, ok := s.changeDeck(w, r, func(ff *FlashFile) (any, error) {
+		var card Flashcard
+		if err := in.apply(&card); err != nil {
+			return nil, err
+		}
+		ff.Cards = append(ff.Cards, card)
+		added = newCardInfo(len(ff.Cards), &card)
+		return added, nil
+	})
+	if ok {
+		reportHook("post-add", path, hookInput{Card: &added})
+	}
+}
+
+func (s *server) editCard(w http.ResponseWriter, r *http.Request) {
+	var in cardInput
+	if err := readBody(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.changeDeck(w, r, func(ff *FlashFile) (any, error) {
+		idx, err := cardNumber(r, ff)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkIfMatch(r, idx, &ff.Cards[idx]); err != nil {
+			return nil, err
+		}
+		// Change a copy so that a bad field leaves the card as it was
+		card := ff.Cards[idx]
+		card.Tags = append([]string(nil), card.Tags...)
+		if err := in.apply(&card); err != nil {
+			return nil, err
+		}
+		ff.Cards[idx] = card
+		return newCardInfo(idx+1, &card), nil
+	})
+}
+
+// deleteCard removes a card. The cards after it move up one number.
+func (s *server) deleteCard(w http.ResponseWriter, r *http.Request) {
+	s.changeDeck(w, r, func(ff *FlashFile) (any, error) {
+		idx, err := cardNumber(r, ff)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkIfMatch(r, idx, &ff.Cards[idx]); err != nil {
+			return nil, err
+		}
+		ff.Cards = append(ff.Cards[:idx], ff.Cards[idx+1:]...)
+		return map[string]int{"deleted": idx + 1}, nil
+	})
+}
+
+// sessionScore is one line of a deck's stats
+type sessionScore struct {
+	Date    string `json:"date"` // 2006/01/02 15:04
+	Correct int    `json:"correct"`
+	Total   int    `json:"total"`
+}
+
+// parseStats reads the session scores of a deck, oldest first, skipping
+// lines that are not scores.
+func parseStats(stats string) []sessionScore {
+	scores := []sessionScore{}
+	for _, line := range strings.Split(stats, "\n") {
+		date, score, ok := strings.Cut(strings.TrimSpace(line), "    ")
+		if !ok {
+			continue
+		}
+		correct, total, ok := strings.Cut(strings.TrimSpace(score), "/")
+		if !ok {
+			continue
+		}
+		c, err1 := strconv.Atoi(correct)
+		t, err2 := strconv.Atoi(total)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		scores = append(scores, sessionScore{Date: strings.TrimSpace(date), Correct: c, Total: t})
+	}
+	return scores
+}
+
+func (s *server) getStats(w http.ResponseWriter, r *http.Request) {
+	ff, err := s.readDeck(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	counts := map[string]int{
+		"total":     len(ff.Cards),
+		"due":       len(dueCards(ff)),
+		"new":       0,
+		"suspended": 0,
+		"buried":    0,
+		"flagged":   0,
+		"leeches":   0,
+	}
+	now := time.Now()
+	for i := range ff.Cards {
+		card := &ff.Cards[i]
+		if card.isNew() {
+			counts["new"]++
+		}
+		if card.Suspended {
+			counts["suspended"]++
+		}
+		if card.isBuried(now) {
+			counts["buried"]++
+		}
+		if card.Flagged {
+			counts["flagged"]++
+		}
+		if card.isLeech() {
+			counts["leeches"]++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": parseStats(ff.Stats), "cards": counts})
+}
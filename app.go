@@ -0,0 +1,23 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// app is what the commands that draw on the terminal run on: the screen,
+// opened when a command needs it, and the output for what they print once
+// it is closed. Tests run the commands on a tcell.SimulationScreen. With
+// --plain, sessions read from in and print to out instead of the screen.
+type app struct {
+	openScreen func() (tcell.Screen, error)
+	in         io.Reader
+	out        io.Writer
+}
+
+// newApp returns the app that runs on the terminal.
+func newApp() *app {
+	return &app{openScreen: newScreen, in: os.Stdin, out: os.Stdout}
+}
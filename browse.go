@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// browseDeck shows every card of a deck, front and back, one at a time.
+func (a *app) browseDeck(filename string) error {
+	unlock, err := lockDeck(filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ff, err := parseFlashFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	if len(ff.Cards) == 0 {
+		fmt.Fprintln(a.out, "No cards in", filename)
+		return nil
+	}
+
+	// Initialize screen
+	screen, err := a.openScreen()
+	if err != nil {
+		return err
+	}
+	defer screen.Fini()
+
+	current := 0
+	changed := false
+	view := cardView{
+		revealed: true,
+		footer: []string{fmt.Sprintf("%s/%s next/previous card, %s raw/markdown, %s help, %s quit",
+			keyLabel("next"), keyLabel("previous"), keyLabel("raw"), keyLabel("help"), keyLabel("quit"))},
+	}
+
+	// leave saves tags added with :tag before returning
+	leave := func() error {
+		if changed {
+			return saveFlashFile(ff)
+		}
+		return nil
+	}
+
+	for {
+		card := &ff.Cards[current]
+		view.label = fmt.Sprintf("Card %d/%d", current+1, len(ff.Cards))
+		if card.Suspended {
+			view.label += " [suspended]"
+		} else if card.isBuried(time.Now()) {
+			view.label += " [buried]"
+		}
+		layout := drawCard(screen, card, &view)
+
+		ev := screen.PollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventMouse:
+			mouseClick(ev) // Only the wheel does something here
+			view.scroll += wheelScroll(ev)
+		case *tcell.EventKey:
+			view.message = ""
+			if keyIs(ev, "quit") || keyIs(ev, "back") {
+				return leave()
+			}
+			if delta := scrollKey(ev, layout.bodyHeight); delta != 0 {
+				view.scroll += delta
+				continue
+			}
+
+			previous := current
+			switch {
+			case keyIs(ev, "next"):
+				current = min(current+1, len(ff.Cards)-1)
+			case keyIs(ev, "previous"):
+				current = max(current-1, 0)
+			case keyIs(ev, "first"):
+				current = 0
+			case keyIs(ev, "last"):
+				current = len(ff.Cards) - 1
+			case keyIs(ev, "raw"):
+				renderRaw = !renderRaw
+			case keyIs(ev, "help"):
+				showHelp(screen, browseActions)
+			case keyIs(ev, "command"):
+				var cmd screenCommand
+				cmd, view.message = promptCommand(screen)
+				switch cmd.name {
+				case "quit":
+					return leave()
+				case "tag":
+					card.addTag(cmd.tag)
+					changed = true
+					view.message = "Tagged " + cmd.tag
+				case "goto":
+					if cmd.line > len(ff.Cards) {
+						view.message = fmt.Sprintf("There are only %d cards", len(ff.Cards))
+						break
+					}
+					current = cmd.line - 1
+				}
+			}
+			if current != previous {
+				view.scroll = 0
+			}
+		}
+	}
+}
+
+// previewCard shows a new card as it will look in a review. It returns
+// false if the user cancelled.
+func previewCard(screen tcell.Screen, card *Flashcard) bool {
+	view := cardView{
+		revealed: true,
+		label:    "(preview)",
+		footer: []string{fmt.Sprintf("Press %s to save, %s to cancel, %s raw/markdown, %s help",
+			keyLabel("confirm"), keyLabel("back"), keyLabel("raw"), keyLabel("help"))},
+	}
+
+	for {
+		layout := drawCard(screen, card, &view)
+
+		ev := screen.PollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventMouse:
+			mouseClick(ev) // Only the wheel does something here
+			view.scroll += wheelScroll(ev)
+		case *tcell.EventKey:
+			switch {
+			case keyIs(ev, "confirm"):
+				return true
+			case keyIs(ev, "back") || ev.Key() == tcell.KeyCtrlC:
+				return false
+			case keyIs(ev, "raw"):
+				renderRaw = !renderRaw
+			case keyIs(ev, "help"):
+				showHelp(screen, previewActions)
+			default:
+				view.scroll += scrollKey(ev, layout.bodyHeight)
+			}
+		}
+	}
+}
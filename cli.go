@@ -0,0 +1,616 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1 // The command failed
+	exitUsage = 2 // The command line was wrong
+)
+
+// version is set for releases with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// command is one of flash's subcommands
+type command struct {
+	name    string // Empty for reviewing a deck, which needs no command name
+	args    string // Positional arguments, for the usage line
+	summary string
+	minArgs int
+	maxArgs int  // -1 for no limit
+	hidden  bool // Left out of the usage and completions
+
+	// setup adds the command's flags to fs and returns the function that
+	// runs the command on a with the positional arguments once they are
+	// parsed
+	setup func(a *app, fs *flag.FlagSet) func(args []string) error
+}
+
+// usageError is a mistake in the command line, as opposed to a failure
+// while running the command
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, a ...any) error {
+	return usageError{fmt.Sprintf(format, a...)}
+}
+
+// noFlags is the setup of a command without flags of its own.
+func noFlags(run func(a *app, args []string) error) func(*app, *flag.FlagSet) func([]string) error {
+	return func(a *app, _ *flag.FlagSet) func([]string) error {
+		return func(args []string) error {
+			return run(a, args)
+		}
+	}
+}
+
+// deckArg returns the deck named by the first argument, or the one
+// findSingleFlashFile picks if there are no arguments.
+func deckArg(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	return findSingleFlashFile()
+}
+
+// reviewDeckCommand reviews the due cards of a deck. It runs when the first
+// argument is a flag or a deck rather than a command name.
+var reviewDeckCommand = &command{
+	args:    "[file.flsh]",
+	summary: "Review the cards that are due",
+	maxArgs: 1,
+	setup: func(a *app, fs *flag.FlagSet) func([]string) error {
+		opts := defaultReviewOptions()
+		addReviewFlags(fs, &opts)
+		return func(args []string) error {
+			filename, err := deckArg(args)
+			if err != nil {
+				return err
+			}
+			return a.studyDeck(filename, dueMode(), opts)
+		}
+	},
+}
+
+// commands lists the subcommands in the order the usage shows them. It is
+// filled in by init since the help command refers back to it.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		reviewDeckCommand,
+		{
+			name:    "review",
+			args:    "[file.flsh]",
+			summary: "Review the cards answered wrong last time",
+			maxArgs: 1,
+			setup: func(a *app, fs *flag.FlagSet) func([]string) error {
+				opts := defaultReviewOptions()
+				addReviewFlags(fs, &opts)
+				return func(args []string) error {
+					filename, err := deckArg(args)
+					if err != nil {
+						return err
+					}
+					return a.studyDeck(filename, wrongMode(), opts)
+				}
+			},
+		},
+		{
+			name:    "resume",
+			args:    "[file.flsh]",
+			summary: "Resume an interrupted session",
+			maxArgs: 1,
+			setup: func(a *app, fs *flag.FlagSet) func([]string) error {
+				plain := false
+				fs.BoolVar(&plain, "plain", false, "read and print plain lines instead of using the full screen")
+				return func(args []string) error {
+					filename, err := deckArg(args)
+					if err != nil {
+						return err
+					}
+					return a.resumeSession(filename, plain)
+				}
+			},
+		},
+		{
+			name:    "cram",
+			args:    "[file.flsh]",
+			summary: "Cram without touching history",
+			maxArgs: 1,
+			setup: func(a *app, fs *flag.FlagSet) func([]string) error {
+				opts := defaultReviewOptions()
+				var filter cramFilter
+				save := false
+				addReviewFlags(fs, &opts)
+				fs.StringVar(&filter.Tag, "tag", "", "only cards with this `tag`")
+				fs.BoolVar(&filter.Flagged, "flagged", false, "only flagged cards")
+				fs.BoolVar(&filter.Wrong, "wrong", false, "only cards failed in their last review")
+				fs.BoolVar(&filter.New, "new", false, "only cards never reviewed")
+				fs.BoolVar(&save, "save", false, "add the grades to the review history")
+				return func(args []string) error {
+					filename, err := deckArg(args)
+					if err != nil {
+						return err
+					}
+					return a.cramDeck(filename, opts, filter, save)
+				}
+			},
+		},
+		{
+			name:    "drill",
+			args:    "[file.flsh]",
+			summary: "Speed drill with a countdown",
+			maxArgs: 1,
+			setup: func(a *app, fs *flag.FlagSet) func([]string) error {
+				opts := defaultReviewOptions()
+				seconds := 0.0
+				addReviewFlags(fs, &opts)
+				fs.Float64Var(&seconds, "seconds", 0, "`seconds` allowed per card (default from the deck, or 10)")
+				return func(args []string) error {
+					filename, err := deckArg(args)
+					if err != nil {
+						return err
+					}
+					if seconds < 0 {
+						return usagef("--seconds must not be negative")
+					}
+					return a.drillDeck(filename, opts, seconds)
+				}
+			},
+		},
+		{
+			name:    "add",
+			args:    "[file.flsh]",
+			summary: "Add cards to a deck",
+			maxArgs: 1,
+			setup: noFlags(func(a *app, args []string) error {
+				filename, err := deckArg(args)
+				if err != nil {
+					return err
+				}
+				return a.addFlashcard(filename)
+			}),
+		},
+		{
+			name:    "browse",
+			args:    "[file.flsh]",
+			summary: "Browse the cards of a deck",
+			maxArgs: 1,
+			setup: noFlags(func(a *app, args []string) error {
+				filename, err := deckArg(args)
+				if err != nil {
+					return err
+				}
+				return a.browseDeck(filename)
+			}),
+		},
+		{
+			name:    "new",
+			args:    "NAME",
+			summary: "Create a new deck (.flsh is added to the name if missing)",
+			minArgs: 1,
+			maxArgs: 1,
+			setup: noFlags(func(a *app, args []string) error {
+				return createNewFlashFile(args[0])
+			}),
+		},
+		{
+			name:    "flagged",
+			args:    "[file.flsh...]",
+			summary: "List flagged cards",
+			maxArgs: -1,
+			setup: noFlags(func(a *app, args []string) error {
+				return a.listFlaggedCards(args)
+			}),
+		},
+		{
+			name:    "leeches",
+			args:    "[file.flsh...]",
+			summary: "List leeches",
+			maxArgs: -1,
+			setup: noFlags(func(a *app, args []string) error {
+				return a.listLeeches(args)
+			}),
+		},
+		{
+			name:    "unsuspend",
+			args:    "[file.flsh [card numbers...]]",
+			summary: "Unsuspend cards, or every card if no numbers are given",
+			maxArgs: -1,
+			setup: noFlags(func(a *app, args []string) error {
+				filename, err := deckArg(args)
+				if err != nil {
+					return err
+				}
+				var numbers []string
+				if len(args) > 1 {
+					numbers = args[1:]
+				}
+				return a.unsuspendCards(filename, numbers)
+			}),
+		},
+		{
+			name:    "import",
+			args:    "file.flsh FILE",
+			summary: "Add the rows of a CSV or TSV file (- for stdin) as cards",
+			minArgs: 2,
+			maxArgs: 2,
+			setup: func(a *app, fs *flag.FlagSet) func([]string) error {
+				var opts tableOptions
+				columns := ""
+				dryRun := false
+				fs.StringVar(&opts.Format, "format", "", "`format` of the file: csv or tsv (default from the file name)")
+				fs.StringVar(&columns, "columns", "", "comma-separated `fields` of the columns: front, back, tags, id, reviews or - to skip (default "+defaultColumns+", or the header)")
+				fs.BoolVar(&opts.Header, "header", false, "skip the first row, and take the columns from it if --columns is not given")
+				fs.BoolVar(&dryRun, "dry-run", false, "show what would be added without changing the deck")
+				return func(args []string) error {
+					if opts.Format == "" {
+						opts.Format = tableFormat(args[1])
+					}
+					if err := checkFormat(opts.Format); err != nil {
+						return err
+					}
+					if columns != "" {
+						var err error
+						if opts.Columns, err = parseColumns(columns); err != nil {
+							return err
+						}
+					}
+					return a.importTable(args[0], args[1], opts, dryRun)
+				}
+			},
+		},
+		{
+			name:    "export",
+			args:    "[file.flsh]",
+			summary: "Print the cards of a deck as CSV or TSV",
+			maxArgs: 1,
+			setup: func(a *app, fs *flag.FlagSet) func([]string) error {
+				opts := tableOptions{Format: "csv"}
+				columns := defaultColumns
+				fs.StringVar(&opts.Format, "format", opts.Format, "`format` to write: csv or tsv")
+				fs.StringVar(&columns, "columns", columns, "comma-separated `fields` of the columns: front, back, tags, id, reviews")
+				fs.BoolVar(&opts.Header, "header", false, "start with a row naming the columns")
+				return func(args []string) error {
+					if err := checkFormat(opts.Format); err != nil {
+						return err
+					}
+					var err error
+					if opts.Columns, err = parseColumns(columns); err != nil {
+						return err
+					}
+					filename, err := deckArg(args)
+					if err != nil {
+						return err
+					}
+					return a.exportTable(filename, opts)
+				}
+			},
+		},
+		{
+			name:    "serve",
+			args:    "[DIR]",
+			summary: "Serve the decks in DIR (default .) to a web browser and the JSON API",
+			maxArgs: 1,
+			setup: func(a *app, fs *flag.FlagSet) func([]string) error {
+				addr := "127.0.0.1:8080"
+				fs.StringVar(&addr, "addr", addr, "`address` to listen on; use 0.0.0.0:8080 to allow other devices")
+				return func(args []string) error {
+					dir := "."
+					if len(args) > 0 {
+						dir = args[0]
+					}
+					return serveDecks(addr, dir)
+				}
+			},
+		},
+		{
+			name:    "config",
+			summary: "Show settings",
+			setup: noFlags(func(a *app, _ []string) error {
+				a.printConfig()
+				return nil
+			}),
+		},
+		{
+			name:    "completion",
+			args:    "bash|zsh|fish",
+			summary: "Print a shell completion script",
+			minArgs: 1,
+			maxArgs: 1,
+			setup: noFlags(func(a *app, args []string) error {
+				return a.printCompletion(args)
+			}),
+		},
+		{
+			name:    "help",
+			args:    "[command]",
+			summary: "Show help for a command",
+			maxArgs: 1,
+			setup: noFlags(func(a *app, args []string) error {
+				if len(args) == 0 {
+					printUsage(a.out)
+					return nil
+				}
+				cmd := findCommand(args[0])
+				if cmd == nil {
+					return usagef("unknown command %q", args[0])
+				}
+				printCommandHelp(a.out, cmd)
+				return nil
+			}),
+		},
+		{
+			name:    "__complete",
+			args:    "commands|decks|tags|flags [args...]",
+			summary: "List completions for the shell completion scripts",
+			minArgs: 1,
+			maxArgs: -1,
+			hidden:  true,
+			setup: noFlags(func(a *app, args []string) error {
+				return a.complete(args)
+			}),
+		},
+	}
+}
+
+// findCommand returns the command with the given name, or nil.
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name != "" && cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// commandLine returns how the command is invoked, e.g. "flash cram".
+func (c *command) commandLine() string {
+	if c.name == "" {
+		return "flash"
+	}
+	return "flash " + c.name
+}
+
+// usageLine returns the command line with placeholders for its arguments.
+func (c *command) usageLine() string {
+	line := c.commandLine()
+	fs, _ := c.flagSet(nil)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		line += " [flags]"
+	}
+	if c.args != "" {
+		line += " " + c.args
+	}
+	return line
+}
+
+// flagSet returns the command's flags and the function that runs it on a.
+// Callers that only want the flags pass a nil app.
+func (c *command) flagSet(a *app) (*flag.FlagSet, func([]string) error) {
+	fs := flag.NewFlagSet(c.commandLine(), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs, c.setup(a, fs)
+}
+
+// run parses the command's flags from args and runs it on a, returning the
+// exit code.
+func (c *command) run(a *app, args []string) int {
+	fs, run := c.flagSet(a)
+	positional, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		printCommandHelp(a.out, c)
+		return exitOK
+	}
+	if err == nil {
+		switch {
+		case len(positional) < c.minArgs:
+			err = usagef("missing arguments")
+		case c.maxArgs >= 0 && len(positional) > c.maxArgs:
+			err = usagef("too many arguments")
+		default:
+			err = run(positional)
+		}
+	} else {
+		err = usageError{err.Error()}
+	}
+
+	var usage usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage):
+		fmt.Fprintf(os.Stderr, "flash: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Usage: %s\n", c.usageLine())
+		fmt.Fprintf(os.Stderr, "Run '%s --help' for more information.\n", c.commandLine())
+		return exitUsage
+	}
+	fmt.Fprintf(os.Stderr, "flash: %v\n", err)
+	return exitError
+}
+
+// printUsage prints the summary of every command.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  flash\tPick a deck and review it\n")
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			fmt.Fprintf(tw, "  %s\t%s\n", cmd.usageLine(), cmd.summary)
+		}
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'flash help COMMAND' or 'flash COMMAND --help' for the flags of a command.")
+	fmt.Fprintln(w, "'flash --version' prints the version.")
+	fmt.Fprintln(w)
+	printExitStatus(w)
+}
+
+func printExitStatus(w io.Writer) {
+	fmt.Fprintln(w, "Exit status is 0 on success, 1 if the command failed and 2 if the command")
+	fmt.Fprintln(w, "line was wrong.")
+}
+
+// printCommandHelp prints the usage and flags of one command.
+func printCommandHelp(w io.Writer, c *command) {
+	fmt.Fprintf(w, "Usage: %s\n\n%s.\n", c.usageLine(), c.summary)
+
+	fs, _ := c.flagSet(nil)
+	var lines [][2]string
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		left := "--" + f.Name
+		if name != "" {
+			left += " " + strings.ToUpper(name)
+		}
+		if f.DefValue != "" && f.DefValue != "0" && f.DefValue != "false" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		lines = append(lines, [2]string{left, usage})
+	})
+	lines = append(lines, [2]string{"--help", "show this help"})
+
+	fmt.Fprintln(w, "\nFlags:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, line := range lines {
+		fmt.Fprintf(tw, "  %s\t%s\n", line[0], line[1])
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	printExitStatus(w)
+}
+
+// versionString returns the version set at build time, or the module
+// version for builds made with go install.
+func versionString() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
+
+// isDeckPath reports whether arg names a deck rather than a command.
+func isDeckPath(arg string) bool {
+	if filepath.Ext(arg) == ".flsh" {
+		return true
+	}
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
+}
+
+// run runs flash with the given command line and returns the exit code.
+func (a *app) run(args []string) int {
+	if len(args) > 0 && (args[0] == "--version" || args[0] == "-version") {
+		fmt.Fprintln(a.out, "flash", versionString())
+		return exitOK
+	}
+
+	if err := loadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "flash: %v\n", err)
+		return exitError
+	}
+
+	if len(args) == 0 {
+		if err := a.pickDeck(); err != nil {
+			fmt.Fprintf(os.Stderr, "flash: %v\n\n", err)
+			printUsage(os.Stderr)
+			return exitError
+		}
+		return exitOK
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help":
+		printUsage(a.out)
+		return exitOK
+	}
+	if cmd := findCommand(args[0]); cmd != nil {
+		return cmd.run(a, args[1:])
+	}
+	if strings.HasPrefix(args[0], "-") || isDeckPath(args[0]) {
+		return reviewDeckCommand.run(a, args)
+	}
+
+	fmt.Fprintf(os.Stderr, "flash: unknown command %q\n", args[0])
+	fmt.Fprintln(os.Stderr, "Run 'flash --help' for usage.")
+	return exitUsage
+}
+
+// complete prints the completions the shell scripts ask for, one per line:
+// the command names, the decks findDecks finds, the tags used in the given
+// decks (or in every deck), or the flags of a command.
+func (a *app) complete(args []string) error {
+	var words []string
+	switch args[0] {
+	case "commands":
+		for _, cmd := range commands {
+			if cmd.name != "" && !cmd.hidden {
+				words = append(words, cmd.name)
+			}
+		}
+	case "decks":
+		words, _ = findDecks()
+	case "tags":
+		files := args[1:]
+		if len(files) == 0 {
+			files, _ = findDecks()
+		}
+		seen := map[string]bool{}
+		for _, filename := range files {
+			ff, err := parseFlashFile(filename)
+			if err != nil {
+				continue
+			}
+			for _, card := range ff.Cards {
+				for _, tag := range card.Tags {
+					if !seen[tag] {
+						seen[tag] = true
+						words = append(words, tag)
+					}
+				}
+			}
+		}
+		sort.Strings(words)
+	case "flags":
+		cmd := reviewDeckCommand
+		if len(args) > 1 && findCommand(args[1]) != nil {
+			cmd = findCommand(args[1])
+		}
+		fs, _ := cmd.flagSet(nil)
+		fs.VisitAll(func(f *flag.Flag) {
+			words = append(words, "--"+f.Name)
+		})
+		words = append(words, "--help")
+	default:
+		return usagef("unknown completion %q", args[0])
+	}
+
+	for _, word := range words {
+		fmt.Fprintln(a.out, word)
+	}
+	return nil
+}
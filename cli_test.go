@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCommandOutput(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := writeDeck(t, testDeck)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--version"}, "flash "},
+		{[]string{"help"}, "Usage:\n"},
+		{[]string{"help", "leeches"}, "flash leeches"},
+		{[]string{"flagged", path}, "No flagged cards\n"},
+		{[]string{"leeches", path}, "No leeches\n"},
+		{[]string{"unsuspend", path}, "Unsuspended 0 card(s)\n"},
+		{[]string{"config"}, "not found, showing defaults\n"},
+		{[]string{"completion", "bash"}, "flash __complete"},
+		{[]string{"__complete", "commands"}, "\nleeches\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		a := &app{out: &out}
+		if code := a.run(test.args); code != exitOK {
+			t.Errorf("flash %s exited with %d", strings.Join(test.args, " "), code)
+		}
+		if !strings.Contains(out.String(), test.want) {
+			t.Errorf("flash %s printed %q, want it to contain %q", strings.Join(test.args, " "), out.String(), test.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import "fmt"
+
+// The completion scripts ask flash itself for command names, flags, decks
+// and tags through the hidden __complete command, so they stay current as
+// decks are added and the config file changes.
+
+const bashCompletion = `# bash completion for flash
+# Load with: source <(flash completion bash)
+_flash() {
+    local cur prev cmd word decks=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    cmd=""
+    if [[ $COMP_CWORD -gt 1 ]]; then
+        cmd="${COMP_WORDS[1]}"
+    fi
+    for word in "${COMP_WORDS[@]}"; do
+        [[ "$word" == *.flsh ]] && decks+=("$word")
+    done
+
+    if [[ "$prev" == "--tag" ]]; then
+        COMPREPLY=($(compgen -W "$(flash __complete tags "${decks[@]}" 2>/dev/null)" -- "$cur"))
+        return
+    fi
+    if [[ "$prev" == "--format" ]]; then
+        COMPREPLY=($(compgen -W "csv tsv" -- "$cur"))
+        return
+    fi
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$(flash __complete flags "$cmd" 2>/dev/null)" -- "$cur"))
+        return
+    fi
+
+    COMPREPLY=()
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "$(flash __complete commands 2>/dev/null)" -- "$cur"))
+    fi
+    case "$cmd" in
+    completion)
+        COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+        return
+        ;;
+    help)
+        COMPREPLY=($(compgen -W "$(flash __complete commands 2>/dev/null)" -- "$cur"))
+        return
+        ;;
+    new | config)
+        return
+        ;;
+    serve)
+        COMPREPLY=($(compgen -d -- "$cur"))
+        return
+        ;;
+    import)
+        if [[ ${#decks[@]} -gt 0 ]]; then
+            COMPREPLY=($(compgen -f -- "$cur"))
+            return
+        fi
+        ;;
+    esac
+    if [[ "$cur" == */* ]]; then
+        COMPREPLY+=($(compgen -f -X '!*.flsh' -- "$cur"))
+    else
+        COMPREPLY+=($(compgen -W "$(flash __complete decks 2>/dev/null)" -- "$cur"))
+    fi
+    COMPREPLY+=($(compgen -d -- "$cur"))
+}
+complete -o filenames -F _flash flash
+`
+
+const zshCompletion = `#compdef flash
+# zsh completion for flash
+# Load with: source <(flash completion zsh)
+_flash() {
+    local cmd=${words[2]}
+    local -a decks
+    decks=(${(M)words:#*.flsh})
+
+    if [[ ${words[CURRENT-1]} == --tag ]]; then
+        compadd -- ${(f)"$(flash __complete tags $decks 2>/dev/null)"}
+        return
+    fi
+    if [[ ${words[CURRENT-1]} == --format ]]; then
+        compadd csv tsv
+        return
+    fi
+    if [[ ${words[CURRENT]} == -* ]]; then
+        compadd -- ${(f)"$(flash __complete flags $cmd 2>/dev/null)"}
+        return
+    fi
+
+    if (( CURRENT == 2 )); then
+        compadd -- ${(f)"$(flash __complete commands 2>/dev/null)"}
+    else
+        case $cmd in
+        completion)
+            compadd bash zsh fish
+            return
+            ;;
+        help)
+            compadd -- ${(f)"$(flash __complete commands 2>/dev/null)"}
+            return
+            ;;
+        new|config)
+            return
+            ;;
+        serve)
+            _files -/
+            return
+            ;;
+        import)
+            if (( $#decks )); then
+                _files -g '*.(csv|tsv|tab)'
+                return
+            fi
+            ;;
+        esac
+    fi
+    compadd -- ${(f)"$(flash __complete decks 2>/dev/null)"}
+    _files -g '*.flsh'
+}
+compdef _flash flash
+`
+
+const fishCompletion = `# fish completion for flash
+# Load with: flash completion fish | source
+function __flash_command
+    set -l words (commandline -opc)
+    if test (count $words) -ge 2
+        echo $words[2]
+    end
+end
+
+function __flash_decks_on_line
+    string match -- '*.flsh' (commandline -opc)
+end
+
+set -l no_decks new config completion help serve
+
+complete -c flash -f
+complete -c flash -n __fish_use_subcommand -a '(flash __complete commands 2>/dev/null)'
+complete -c flash -n "not __fish_seen_subcommand_from $no_decks" -a '(flash __complete decks 2>/dev/null)'
+complete -c flash -n "not __fish_seen_subcommand_from $no_decks" -a '(__fish_complete_suffix .flsh)'
+complete -c flash -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+complete -c flash -n '__fish_seen_subcommand_from serve' -a '(__fish_complete_directories)'
+complete -c flash -n '__fish_seen_subcommand_from import' -a '(__fish_complete_suffix .csv) (__fish_complete_suffix .tsv)'
+complete -c flash -n '__fish_seen_subcommand_from help' -a '(flash __complete commands 2>/dev/null)'
+complete -c flash -n 'string match -q -- "-*" (commandline -ct)' -a '(flash __complete flags (__flash_command) 2>/dev/null)'
+complete -c flash -l tag -x -a '(flash __complete tags (__flash_decks_on_line) 2>/dev/null)'
+complete -c flash -l format -x -a 'csv tsv'
+`
+
+// printCompletion prints the completion script for a shell.
+func (a *app) printCompletion(args []string) error {
+	switch args[0] {
+	case "bash":
+		fmt.Fprint(a.out, bashCompletion)
+	case "zsh":
+		fmt.Fprint(a.out, zshCompletion)
+	case "fish":
+		fmt.Fprint(a.out, fishCompletion)
+	default:
+		return usagef("unknown shell %q, expected bash, zsh or fish", args[0])
+	}
+	return nil
+}
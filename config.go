@@ -0,0 +1,410 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config holds the settings read from the user's config file
+type config struct {
+	Theme       string              // Name of the color theme
+	Colors      map[string]string   // Style overrides by role, such as title = "bold #ff8700"
+	DeckDirs    []string            // Searched for decks besides the current directory
+	DefaultDeck string              // Used by commands given no deck
+	Review      reviewOptions       // Defaults for the review flags
+	Keys        map[string][]string // Keys bound to each action
+	Mouse       bool                // Click and scroll with the mouse
+	Hooks       map[string]string   // Commands run after a deck is saved, by hook name
+}
+
+// cfg is the effective configuration, loaded once at startup
+var cfg = defaultConfig()
+
+func defaultConfig() config {
+	return config{
+		Theme:  "dark",
+		Colors: map[string]string{},
+		Review: reviewOptions{
+			Steps: []time.Duration{time.Minute, 10 * time.Minute},
+		},
+		Keys:  copyKeys(defaultKeys),
+		Mouse: true,
+		Hooks: map[string]string{},
+	}
+}
+
+// configPath returns the location of the config file, following the XDG
+// base directory spec.
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "flash", "config.toml")
+}
+
+// loadConfig reads the config file into cfg. A missing file leaves the
+// defaults in place.
+func loadConfig() error {
+	path := configPath()
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading config: %v", err)
+	}
+
+	c, err := parseConfig(string(content))
+	if err != nil {
+		return fmt.Errorf("error in %s: %v", path, err)
+	}
+	cfg = c
+	return nil
+}
+
+// parseConfig reads the settings in a config file on top of the defaults.
+func parseConfig(content string) (config, error) {
+	c := defaultConfig()
+
+	values, err := parseTOML(content)
+	if err != nil {
+		return c, err
+	}
+
+	for key, value := range values {
+		section, name := "", key
+		if dot := strings.LastIndex(key, "."); dot >= 0 {
+			section, name = key[:dot], key[dot+1:]
+		}
+
+		switch {
+		case key == "theme":
+			c.Theme, err = stringValue(key, value)
+			if _, ok := themes[c.Theme]; err == nil && !ok {
+				err = fmt.Errorf("unknown theme %q", c.Theme)
+			}
+		case key == "mouse":
+			c.Mouse, err = boolValue(key, value)
+		case key == "deck_dirs":
+			c.DeckDirs, err = stringsValue(key, value)
+			for i := range c.DeckDirs {
+				c.DeckDirs[i] = expandHome(c.DeckDirs[i])
+			}
+		case key == "default_deck":
+			c.DefaultDeck, err = stringValue(key, value)
+			c.DefaultDeck = expandHome(c.DefaultDeck)
+		case section == "review":
+			err = setReviewOption(&c.Review, name, value)
+		case section == "keys":
+			c.Keys[name], err = parseKeyBinding(name, value)
+		case section == "hooks":
+			if !slices.Contains(hookNames, name) {
+				err = fmt.Errorf("unknown hook %q", name)
+				break
+			}
+			c.Hooks[name], err = stringValue(key, value)
+		case section == "colors":
+			if _, ok := styleRoles[name]; !ok {
+				err = fmt.Errorf("unknown color role %q", name)
+				break
+			}
+			c.Colors[name], err = stringValue(key, value)
+			if err == nil {
+				_, err = parseStyleSpec(c.Colors[name])
+			}
+		default:
+			err = fmt.Errorf("unknown setting %q", key)
+		}
+		if err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
+
+// setReviewOption sets one of the review defaults from the [review]
+// section, named like the review flags.
+func setReviewOption(opts *reviewOptions, name string, value any) error {
+	key := "review." + name
+	var err error
+	switch name {
+	case "relearn":
+		opts.Relearn, err = boolValue(key, value)
+	case "shuffle":
+		opts.Shuffle, err = boolValue(key, value)
+	case "limit":
+		opts.Limit, err = intValue(key, value)
+	case "minutes":
+		opts.Minutes, err = intValue(key, value)
+	case "steps":
+		// Either "1m,10m" as on the command line or ["1m", "10m"]
+		var steps []string
+		steps, err = stringsValue(key, value)
+		if err == nil {
+			err = stepsFlag{&opts.Steps}.Set(strings.Join(steps, ","))
+		}
+	default:
+		err = fmt.Errorf("unknown setting %q", key)
+	}
+	return err
+}
+
+// expandHome replaces a leading ~ with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+func stringValue(key string, value any) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", key)
+	}
+	return s, nil
+}
+
+// stringsValue accepts an array of strings or a single string.
+func stringsValue(key string, value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s must be a string or an array of strings", key)
+}
+
+func boolValue(key string, value any) (bool, error) {
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s must be true or false", key)
+	}
+	return b, nil
+}
+
+func intValue(key string, value any) (int, error) {
+	n, ok := value.(int)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("%s must be a whole number", key)
+	}
+	return n, nil
+}
+
+// formatConfig writes a config in the config file's format, with every
+// setting spelled out.
+func formatConfig(c config) string {
+	var b strings.Builder
+	quote := func(s string) string { return strconv.Quote(s) }
+	list := func(items []string) string {
+		var quoted []string
+		for _, item := range items {
+			quoted = append(quoted, quote(item))
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	fmt.Fprintf(&b, "theme = %s\n", quote(c.Theme))
+	fmt.Fprintf(&b, "default_deck = %s\n", quote(c.DefaultDeck))
+	fmt.Fprintf(&b, "deck_dirs = %s\n", list(c.DeckDirs))
+	fmt.Fprintf(&b, "mouse = %t\n", c.Mouse)
+
+	b.WriteString("\n[review]\n")
+	fmt.Fprintf(&b, "relearn = %t\n", c.Review.Relearn)
+	fmt.Fprintf(&b, "steps = %s\n", quote(stepsFlag{&c.Review.Steps}.String()))
+	fmt.Fprintf(&b, "shuffle = %t\n", c.Review.Shuffle)
+	fmt.Fprintf(&b, "limit = %d\n", c.Review.Limit)
+	fmt.Fprintf(&b, "minutes = %d\n", c.Review.Minutes)
+
+	b.WriteString("\n[colors]\n")
+	var roles []string
+	for role := range c.Colors {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		fmt.Fprintf(&b, "%s = %s\n", role, quote(c.Colors[role]))
+	}
+
+	b.WriteString("\n[keys]\n")
+	for _, action := range keyActions() {
+		fmt.Fprintf(&b, "%s = %s\n", action, list(c.Keys[action]))
+	}
+
+	b.WriteString("\n[hooks]\n")
+	for _, name := range hookNames {
+		fmt.Fprintf(&b, "%s = %s\n", name, quote(c.Hooks[name]))
+	}
+
+	return b.String()
+}
+
+// printConfig prints the effective configuration for flash config.
+func (a *app) printConfig() {
+	path := configPath()
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintf(a.out, "# %s not found, showing defaults\n", path)
+	} else {
+		fmt.Fprintf(a.out, "# %s\n", path)
+	}
+	fmt.Fprint(a.out, formatConfig(cfg))
+}
+
+// parseTOML reads the part of TOML the config file needs: [section]
+// headers, and key = value pairs whose values are strings, integers,
+// booleans or arrays of strings. Keys are returned as "section.key".
+func parseTOML(content string) (map[string]any, error) {
+	values := map[string]any{}
+	section := ""
+
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: bad section header", n+1)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		if section != "" {
+			key = section + "." + key
+		}
+
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// stripComment removes a # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseTOMLValue(raw string) (any, error) {
+	switch {
+	case raw == "true" || raw == "false":
+		return raw == "true", nil
+
+	case strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, "'"):
+		s, rest, err := parseTOMLString(raw)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("unexpected text after string")
+		}
+		return s, nil
+
+	case strings.HasPrefix(raw, "["):
+		var items []string
+		rest := strings.TrimSpace(raw[1:])
+		for !strings.HasPrefix(rest, "]") {
+			s, after, err := parseTOMLString(rest)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, s)
+			rest = strings.TrimSpace(after)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, fmt.Errorf("expected , or ] in array")
+			}
+		}
+		return items, nil
+
+	default:
+		n, err := strconv.ParseInt(strings.ReplaceAll(raw, "_", ""), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad value %q", raw)
+		}
+		return int(n), nil
+	}
+}
+
+// parseTOMLString reads a basic "..." or literal '...' string at the start
+// of raw and returns it with the text after it.
+func parseTOMLString(raw string) (string, string, error) {
+	if raw == "" || (raw[0] != '"' && raw[0] != '\'') {
+		return "", "", fmt.Errorf("expected a string")
+	}
+	quote := raw[0]
+	if quote == '\'' {
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return raw[1 : end+1], raw[end+2:], nil
+	}
+
+	var s strings.Builder
+	for i := 1; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			return s.String(), raw[i+1:], nil
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				s.WriteByte('\n')
+			case 't':
+				s.WriteByte('\t')
+			default:
+				s.WriteByte(raw[i])
+			}
+		default:
+			s.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
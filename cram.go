@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// cramFilter selects the cards drilled by a cram session
+type cramFilter struct {
+	Tag     string
+	Flagged bool
+	Wrong   bool
+	New     bool
+}
+
+func (f cramFilter) matches(card *Flashcard) bool {
+	if f.Tag != "" && !card.hasTag(f.Tag) {
+		return false
+	}
+	if f.Flagged && !card.Flagged {
+		return false
+	}
+	if f.Wrong && !card.lastReviewWrong() {
+		return false
+	}
+	if f.New && !card.isNew() {
+		return false
+	}
+	return true
+}
+
+// copyFlashFile returns a copy of ff whose cards can be changed without
+// affecting the original.
+func copyFlashFile(ff *FlashFile) *FlashFile {
+	cp := *ff
+	cp.Cards = make([]Flashcard, len(ff.Cards))
+	for i, card := range ff.Cards {
+		card.Tags = append([]string(nil), card.Tags...)
+		cp.Cards[i] = card
+	}
+	return &cp
+}
+
+// cramDeck drills a deck without touching its review history or stats.
+// Grades are kept in memory unless save is set, in which case they are
+// added to the review history (but still not to the stats).
+func (a *app) cramDeck(filename string, opts reviewOptions, filter cramFilter, save bool) error {
+	original, err := parseFlashFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	ff := original
+	if !save {
+		ff = copyFlashFile(original)
+		opts.DryRun = true
+	}
+	return a.runSession(ff, cramMode(filter, save), opts)
+}
+
+// cramMode drills the cards matching filter. Cram sessions are never saved
+// for resuming or added to the stats.
+func cramMode(filter cramFilter, save bool) sessionMode {
+	return sessionMode{
+		Name:      "cram",
+		Empty:     "No cards match the cram filters",
+		TitlePage: true,
+		Save:      save,
+		Select: func(ff *FlashFile) []int {
+			var indices []int
+			now := time.Now()
+			for i := range ff.Cards {
+				if ff.Cards[i].isAvailable(now) && filter.matches(&ff.Cards[i]) {
+					indices = append(indices, i)
+				}
+			}
+			return indices
+		},
+		Summary: func(screen tcell.Screen, ff *FlashFile, state *sessionState, result reviewResult, _ string) {
+			showCramSummary(screen, ff, state, result)
+		},
+	}
+}
+
+// showCramSummary shows the score of a cram session and the missed cards.
+func showCramSummary(screen tcell.Screen, ff *FlashFile, state *sessionState, result reviewResult) {
+	for {
+		screen.Clear()
+		drawText(screen, 0, 0, "Cram summary:", styleTitle)
+		drawText(screen, 0, 1, fmt.Sprintf("%d/%d correct (%d%%)", state.Correct, state.Total, state.Correct*100/state.Total), styleScore)
+
+		y := 3
+		if len(result.Missed) > 0 {
+			drawText(screen, 0, y, "Missed:", styleTitle)
+			y++
+			for _, idx := range result.Missed {
+				drawText(screen, 2, y, fmt.Sprintf("%d. %s", idx+1, firstLine(ff.Cards[idx].Front)), styleWrong)
+				y++
+			}
+			y++
+		}
+		if remaining := remainingSummary(state, result); remaining != "" {
+			drawText(screen, 0, y, remaining, stylePrompt)
+			y += strings.Count(remaining, "\n") + 2
+		}
+
+		drawText(screen, 0, y, "Press any key to exit", stylePrompt)
+		screen.Show()
+
+		switch screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			return
+		}
+	}
+}
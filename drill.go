@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const defaultDrillSeconds = 10
+
+// drillDeck runs a speed drill: every front gets a countdown, and a card
+// that runs out of time is revealed and graded wrong. Grades are recorded
+// like a regular review. With seconds 0 the deck's drill-seconds option
+// or the default is used.
+func (a *app) drillDeck(filename string, opts reviewOptions, seconds float64) error {
+	ff, err := parseFlashFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+
+	// The countdown can also be set per deck with "drill-seconds: N"
+	if seconds <= 0 {
+		seconds = float64(ff.intOption("drill-seconds", defaultDrillSeconds))
+	}
+	opts.Countdown = time.Duration(seconds * float64(time.Second))
+
+	return a.runSession(ff, drillMode(), opts)
+}
+
+// drillMode runs every available card against the countdown and records
+// the score like a regular review, but is not saved for resuming.
+func drillMode() sessionMode {
+	return sessionMode{
+		Name:      "drill",
+		Empty:     "No cards to drill",
+		TitlePage: true,
+		Stats:     true,
+		Save:      true,
+		Select:    availableCards,
+		Summary: func(screen tcell.Screen, _ *FlashFile, state *sessionState, result reviewResult, _ string) {
+			showDrillSummary(screen, state, result)
+		},
+	}
+}
+
+// showDrillSummary shows the accuracy and average reveal time of a drill.
+func showDrillSummary(screen tcell.Screen, state *sessionState, result reviewResult) {
+	average := result.Latency / time.Duration(state.Total)
+
+	for {
+		screen.Clear()
+		drawText(screen, 0, 0, "Speed drill summary:", styleTitle)
+		drawText(screen, 0, 2, fmt.Sprintf("Accuracy:        %d/%d (%d%%)", state.Correct, state.Total, state.Correct*100/state.Total), styleScore)
+		drawText(screen, 0, 3, fmt.Sprintf("Average latency: %.1fs", average.Seconds()), styleScore)
+		drawText(screen, 0, 4, fmt.Sprintf("Timed out:       %d", result.TimedOut), styleScore)
+
+		y := 6
+		if remaining := remainingSummary(state, result); remaining != "" {
+			drawText(screen, 0, y, remaining, stylePrompt)
+			y += strings.Count(remaining, "\n") + 2
+		}
+
+		drawText(screen, 0, y, "Press any key to exit", stylePrompt)
+		screen.Show()
+
+		switch screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			return
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// lexer describes just enough of a language to highlight code cards
+type lexer struct {
+	keywords      map[string]bool
+	types         map[string]bool // Built-in types and functions
+	lineComments  []string
+	blockComment  [2]string // Start and end, empty if the language has none
+	quotes        string    // Characters that start a string
+	rawQuote      byte      // Quote of strings that may span lines, 0 if none
+	ignoreCase    bool      // Keywords match in any case
+	keys          bool      // Highlight "key:" at the start of a line
+	variables     bool      // Highlight $VAR and ${VAR}
+	commentNeedWS bool      // Line comments only start after whitespace
+}
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+var (
+	goLexer = &lexer{
+		keywords: wordSet(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var`),
+		types: wordSet(`bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune
+			string uint uint8 uint16 uint32 uint64 uintptr any comparable true false nil iota
+			append cap clear close complex copy delete imag len make max min new panic print println real recover`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		rawQuote:     '`',
+	}
+
+	shellLexer = &lexer{
+		keywords: wordSet(`if then else elif fi for while until do done case esac function in return
+			local export select time declare readonly`),
+		types: wordSet(`echo cd printf read set unset source exit test true false shift trap eval exec
+			alias cat grep sed awk xargs find sudo`),
+		lineComments:  []string{"#"},
+		quotes:        "\"'",
+		variables:     true,
+		commentNeedWS: true,
+	}
+
+	sqlLexer = &lexer{
+		keywords: wordSet(`SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER
+			INDEX VIEW JOIN LEFT RIGHT INNER OUTER FULL CROSS ON GROUP BY ORDER HAVING LIMIT OFFSET AS
+			AND OR NOT NULL IS IN EXISTS DISTINCT UNION ALL CASE WHEN THEN ELSE END PRIMARY KEY FOREIGN
+			REFERENCES DEFAULT BEGIN COMMIT ROLLBACK TRANSACTION WITH LIKE BETWEEN ASC DESC RETURNING
+			UNIQUE CONSTRAINT CHECK IF`),
+		types: wordSet(`INT INTEGER BIGINT SMALLINT SERIAL VARCHAR CHAR TEXT BOOLEAN BOOL DATE TIME
+			TIMESTAMP NUMERIC DECIMAL REAL FLOAT JSON JSONB COUNT SUM AVG MIN MAX COALESCE NOW TRUE FALSE`),
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'\"",
+		ignoreCase:   true,
+	}
+
+	jsonLexer = &lexer{
+		keywords: wordSet(`true false null`),
+		quotes:   "\"",
+	}
+
+	yamlLexer = &lexer{
+		keywords:      wordSet(`true false null yes no on off ~`),
+		lineComments:  []string{"#"},
+		quotes:        "\"'",
+		keys:          true,
+		commentNeedWS: true,
+	}
+)
+
+// lexers maps the language tag of a fenced code block to its lexer
+var lexers = map[string]*lexer{
+	"go":         goLexer,
+	"golang":     goLexer,
+	"sh":         shellLexer,
+	"bash":       shellLexer,
+	"shell":      shellLexer,
+	"zsh":        shellLexer,
+	"console":    shellLexer,
+	"sql":        sqlLexer,
+	"postgresql": sqlLexer,
+	"mysql":      sqlLexer,
+	"sqlite":     sqlLexer,
+	"json":       jsonLexer,
+	"yaml":       yamlLexer,
+	"yml":        yamlLexer,
+}
+
+// highlightState carries constructs that span lines
+type highlightState struct {
+	inComment bool // Inside a block comment
+	inRaw     bool // Inside a multi-line string
+}
+
+func isIdentStart(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func isIdentByte(b byte) bool {
+	return isIdentStart(b) || b >= '0' && b <= '9'
+}
+
+// highlightLine splits one line of code into styled spans. The text of the
+// spans joined together is always exactly the line.
+func highlightLine(lx *lexer, line string, state *highlightState) []textSpan {
+	var spans []textSpan
+	emit := func(text string, style tcell.Style) {
+		if text == "" {
+			return
+		}
+		// Merge with the previous span when the style is the same
+		if n := len(spans); n > 0 && spans[n-1].style == style {
+			spans[n-1].text += text
+			return
+		}
+		spans = append(spans, textSpan{text, style})
+	}
+
+	i := 0
+
+	// YAML keys, optionally after indentation and a list dash
+	if lx.keys {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		start := indent
+		if strings.HasPrefix(line[start:], "- ") {
+			start += 2
+		}
+		if colon := strings.Index(line[start:], ":"); colon > 0 {
+			key := line[start : start+colon]
+			if !strings.ContainsAny(key, "#\"'") && (start+colon+1 == len(line) || line[start+colon+1] == ' ') {
+				emit(line[:start], styleCode)
+				emit(key, styleKey)
+				i = start + colon
+			}
+		}
+	}
+
+	for i < len(line) {
+		rest := line[i:]
+
+		// Continue a block comment or multi-line string from earlier lines
+		if state.inComment {
+			end := strings.Index(rest, lx.blockComment[1])
+			if end < 0 {
+				emit(rest, styleComment)
+				return spans
+			}
+			end += len(lx.blockComment[1])
+			emit(rest[:end], styleComment)
+			state.inComment = false
+			i += end
+			continue
+		}
+		if state.inRaw {
+			end := strings.IndexByte(rest, lx.rawQuote)
+			if end < 0 {
+				emit(rest, styleString)
+				return spans
+			}
+			emit(rest[:end+1], styleString)
+			state.inRaw = false
+			i += end + 1
+			continue
+		}
+
+		// Comments
+		commentAllowed := !lx.commentNeedWS || i == 0 || line[i-1] == ' ' || line[i-1] == '\t'
+		isComment := false
+		for _, prefix := range lx.lineComments {
+			if commentAllowed && strings.HasPrefix(rest, prefix) {
+				isComment = true
+			}
+		}
+		if isComment {
+			emit(rest, styleComment)
+			return spans
+		}
+		if lx.blockComment[0] != "" && strings.HasPrefix(rest, lx.blockComment[0]) {
+			state.inComment = true
+			emit(lx.blockComment[0], styleComment)
+			i += len(lx.blockComment[0])
+			continue
+		}
+
+		c := line[i]
+		switch {
+		case strings.IndexByte(lx.quotes, c) >= 0:
+			// Strings, with backslash escapes except in raw strings
+			j := i + 1
+			for j < len(line) && line[j] != c {
+				if line[j] == '\\' && c != lx.rawQuote {
+					j++
+				}
+				j++
+			}
+			if j >= len(line) {
+				if c == lx.rawQuote {
+					state.inRaw = true
+				}
+				emit(line[i:], styleString)
+				return spans
+			}
+			style := styleString
+			// A JSON string followed by a colon is a key
+			if lx == jsonLexer && strings.HasPrefix(strings.TrimLeft(line[j+1:], " "), ":") {
+				style = styleKey
+			}
+			emit(line[i:j+1], style)
+			i = j + 1
+
+		case lx.variables && c == '$' && i+1 < len(line) && (line[i+1] == '{' || isIdentStart(line[i+1])):
+			j := i + 1
+			if line[j] == '{' {
+				for j < len(line) && line[j] != '}' {
+					j++
+				}
+				if j < len(line) {
+					j++
+				}
+			} else {
+				for j < len(line) && isIdentByte(line[j]) {
+					j++
+				}
+			}
+			emit(line[i:j], styleType)
+			i = j
+
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentByte(line[i-1])):
+			j := i
+			for j < len(line) && (isIdentByte(line[j]) || line[j] == '.') {
+				j++
+			}
+			emit(line[i:j], styleNumber)
+			i = j
+
+		case isIdentStart(c) || (c == '~' && lx.keys):
+			j := i + 1
+			for j < len(line) && isIdentByte(line[j]) {
+				j++
+			}
+			word := line[i:j]
+			lookup := word
+			if lx.ignoreCase {
+				lookup = strings.ToUpper(word)
+			}
+			switch {
+			case lx.keywords[lookup]:
+				emit(word, styleKeyword)
+			case lx.types[lookup]:
+				emit(word, styleType)
+			default:
+				emit(word, styleCode)
+			}
+			i = j
+
+		default:
+			emit(line[i:i+1], styleCode)
+			i++
+		}
+	}
+
+	return spans
+}
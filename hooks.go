@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// hookNames are the hooks that can be set in the [hooks] section of the
+// config file. Each is a shell command run after a deck has been saved:
+//
+//	post-session  after a review, cram or drill session is saved
+//	post-add      after a card is added
+//	on-leech      after a card that has just become a leech is saved
+//
+// The command is run by the shell in the deck's directory. It gets the
+// deck's path as $1 and in $FLASH_DECK, and a JSON hookInput on stdin.
+var hookNames = []string{"post-session", "post-add", "on-leech"}
+
+// hookTimeout is how long a hook may run before it is killed
+const hookTimeout = time.Minute
+
+// hookInput is what a hook reads on stdin
+type hookInput struct {
+	Hook    string          `json:"hook"`
+	Deck    string          `json:"deck"` // Absolute path of the deck
+	Session *sessionSummary `json:"session,omitempty"`
+	Card    *cardInfo       `json:"card,omitempty"`
+}
+
+// sessionSummary describes a finished session to the post-session hook
+type sessionSummary struct {
+	Mode     string  `json:"mode"` // "all", "wrong", "cram", "drill" or "web"
+	Correct  int     `json:"correct"`
+	Total    int     `json:"total"`              // Cards reviewed
+	Duration float64 `json:"duration,omitempty"` // Seconds, left out if unknown
+	Missed   []int   `json:"missed"`             // Numbers of the cards answered wrong
+	Stopped  string  `json:"stopped,omitempty"`  // Why a limit ended the session early
+}
+
+func newSessionSummary(state *sessionState, result reviewResult, duration time.Duration) *sessionSummary {
+	summary := &sessionSummary{
+		Mode:     state.Mode,
+		Correct:  state.Correct,
+		Total:    state.Total,
+		Duration: duration.Round(time.Second).Seconds(),
+		Missed:   []int{},
+		Stopped:  result.Stopped,
+	}
+	for _, idx := range result.Missed {
+		summary.Missed = append(summary.Missed, idx+1)
+	}
+	return summary
+}
+
+// runHook runs the named hook for a deck if the config sets one. Its
+// output goes to stderr so that it cannot mix with what flash prints.
+func runHook(name, deck string, input hookInput) error {
+	command := cfg.Hooks[name]
+	if command == "" {
+		return nil
+	}
+
+	path, err := filepath.Abs(deck)
+	if err != nil {
+		return fmt.Errorf("%s hook: %v", name, err)
+	}
+	input.Hook = name
+	input.Deck = path
+	data, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("%s hook: %v", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command, "sh", path)
+	}
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = append(os.Environ(), "FLASH_HOOK="+name, "FLASH_DECK="+path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %v", name, err)
+	}
+	return nil
+}
+
+// reportHook runs a hook and prints its error, if any. A failed hook does
+// not fail the command, as the deck has already been saved.
+func reportHook(name, deck string, input hookInput) {
+	if err := runHook(name, deck, input); err != nil {
+		fmt.Fprintf(os.Stderr, "flash: %v\n", err)
+	}
+}
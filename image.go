@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// reImage matches an image on a line of its own: ![alt](path)
+var reImage = regexp.MustCompile(`^!\[([^\]]*)\]\(([^)\s]+)\)$`)
+
+// imageKey is an image drawn at a size
+type imageKey struct {
+	path          string
+	width, height int
+}
+
+// cachedImage is a drawn image, kept so redraws, such as the countdown's
+// ticks, neither decode nor scale it again
+type cachedImage struct {
+	lines   []styledLine
+	modTime time.Time
+}
+
+// imageCache holds at most maxCachedImages drawn images
+var imageCache = map[imageKey]cachedImage{}
+
+const maxCachedImages = 32
+
+// loadImage decodes a PNG, JPEG or GIF file.
+func loadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+	return img, nil
+}
+
+// renderImage lays out the image at path, relative to dir, in at most
+// width columns and height rows, reusing the last layout at that size
+// while the file is unchanged. If it cannot be shown, a line naming it is
+// returned instead.
+func renderImage(alt, path, dir string, width, height int) []styledLine {
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	failed := func(err error) []styledLine {
+		if alt == "" {
+			alt = filepath.Base(path)
+		}
+		return appendWrapped(nil, fmt.Sprintf("[image: %s] %v", alt, err), width, styleWrong)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return failed(err)
+	}
+	key := imageKey{path, width, height}
+	if cached, ok := imageCache[key]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.lines
+	}
+
+	img, err := loadImage(path)
+	if err != nil {
+		return failed(err)
+	}
+	lines := halfBlocks(img, width, height)
+
+	// Make room by dropping any one image, which is cheap to draw again
+	if _, ok := imageCache[key]; !ok && len(imageCache) >= maxCachedImages {
+		for k := range imageCache {
+			delete(imageCache, k)
+			break
+		}
+	}
+	imageCache[key] = cachedImage{lines, info.ModTime()}
+	return lines
+}
+
+// halfBlocks draws an image with one cell for every two pixels stacked
+// vertically: the upper half block takes the top pixel's color and the cell
+// background the bottom one's. Since cells are about twice as tall as they
+// are wide, the pixels come out square. The image is shrunk to fit, keeping
+// its proportions, but never enlarged.
+func halfBlocks(img image.Image, width, height int) []styledLine {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW == 0 || srcH == 0 || width < 1 || height < 1 {
+		return nil
+	}
+
+	scale := 1.0
+	if s := float64(width) / float64(srcW); s < scale {
+		scale = s
+	}
+	if s := float64(height*2) / float64(srcH); s < scale {
+		scale = s
+	}
+	cols := max(1, int(float64(srcW)*scale))
+	pixelRows := max(1, int(float64(srcH)*scale))
+
+	// pixel averages the source pixels under one scaled pixel
+	pixel := func(x, y int) (color.NRGBA, bool) {
+		x0 := bounds.Min.X + x*srcW/cols
+		x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/cols)
+		y0 := bounds.Min.Y + y*srcH/pixelRows
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/pixelRows)
+
+		var r, g, b, a, n uint64
+		for sy := y0; sy < y1; sy++ {
+			for sx := x0; sx < x1; sx++ {
+				c := color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA)
+				r += uint64(c.R)
+				g += uint64(c.G)
+				b += uint64(c.B)
+				a += uint64(c.A)
+				n++
+			}
+		}
+		avg := color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)}
+		return avg, avg.A >= 128
+	}
+	rgb := func(c color.NRGBA) tcell.Color {
+		return tcell.NewRGBColor(int32(c.R), int32(c.G), int32(c.B))
+	}
+
+	var lines []styledLine
+	for y := 0; y < pixelRows; y += 2 {
+		var line styledLine
+		for x := 0; x < cols; x++ {
+			top, topOpaque := pixel(x, y)
+			bottom, bottomOpaque := color.NRGBA{}, false
+			if y+1 < pixelRows {
+				bottom, bottomOpaque = pixel(x, y+1)
+			}
+
+			// Transparent halves show the terminal's own background
+			text, style := " ", styleDefault
+			switch {
+			case topOpaque && bottomOpaque:
+				text, style = "▀", styleDefault.Foreground(rgb(top)).Background(rgb(bottom))
+			case topOpaque:
+				text, style = "▀", styleDefault.Foreground(rgb(top))
+			case bottomOpaque:
+				text, style = "▄", styleDefault.Foreground(rgb(bottom))
+			}
+
+			if n := len(line.spans); n > 0 && line.spans[n-1].style == style {
+				line.spans[n-1].text += text
+			} else {
+				line.spans = append(line.spans, textSpan{text, style})
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
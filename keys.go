@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// defaultKeys lists the keys bound to each action. The [keys] section of
+// the config file replaces the keys of any action it names. Ctrl-C always
+// quits as well.
+var defaultKeys = map[string][]string{
+	"quit":      {"q"},
+	"confirm":   {"enter"}, // Continue past the title page, save a previewed card
+	"back":      {"esc"},   // Step back a screen, discarding a previewed card
+	"help":      {"?"},
+	"command":   {":"},
+	"reveal":    {"space", "enter"},
+	"correct":   {"y", "Y"},
+	"wrong":     {"n", "N"},
+	"suspend":   {"s"},
+	"bury":      {"b"},
+	"flag":      {"f"},
+	"raw":       {"m"}, // Switch between raw and rendered card text
+	"up":        {"k", "up"},
+	"down":      {"j", "down"},
+	"page-up":   {"pgup"},
+	"page-down": {"pgdn"},
+	"first":     {"g", "home"},
+	"last":      {"G", "end"},
+	"next":      {"n", "l", "right"}, // Next card when browsing
+	"previous":  {"p", "h", "left"},
+	"resume":    {"r", "enter"}, // Continue an unfinished session
+	"restart":   {"n"},          // Start over instead
+}
+
+// keyNames holds the names of keys other than single characters, as
+// written in the config file
+var keyNames = func() map[string]bool {
+	names := map[string]bool{"space": true}
+	for _, name := range tcell.KeyNames {
+		names[strings.ToLower(name)] = true
+	}
+	return names
+}()
+
+func copyKeys(keys map[string][]string) map[string][]string {
+	copied := map[string][]string{}
+	for action, names := range keys {
+		copied[action] = append([]string(nil), names...)
+	}
+	return copied
+}
+
+// validKey reports whether name is a key the config file can bind.
+func validKey(name string) bool {
+	return utf8.RuneCountInString(name) == 1 || keyNames[name]
+}
+
+// keyName returns the config file name of the key in ev.
+func keyName(ev *tcell.EventKey) string {
+	if ev.Key() == tcell.KeyRune {
+		if ev.Rune() == ' ' {
+			return "space"
+		}
+		return string(ev.Rune())
+	}
+	return strings.ToLower(tcell.KeyNames[ev.Key()])
+}
+
+// keyIs reports whether ev is one of the keys bound to action.
+func keyIs(ev *tcell.EventKey, action string) bool {
+	if action == "quit" && ev.Key() == tcell.KeyCtrlC {
+		return true
+	}
+	name := keyName(ev)
+	for _, key := range cfg.Keys[action] {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// keyLabel returns how prompts name the first key bound to action.
+func keyLabel(action string) string {
+	keys := cfg.Keys[action]
+	if len(keys) == 0 {
+		return "(unbound)"
+	}
+	if utf8.RuneCountInString(keys[0]) == 1 {
+		return keys[0]
+	}
+	return strings.ToUpper(keys[0])
+}
+
+// keyActions returns the names of all actions in order.
+func keyActions() []string {
+	var actions []string
+	for action := range defaultKeys {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// parseKeyBinding reads the keys for one action from the config file,
+// given as a single key or an array of keys.
+func parseKeyBinding(action string, value any) ([]string, error) {
+	if _, ok := defaultKeys[action]; !ok {
+		return nil, fmt.Errorf("unknown key action %q", action)
+	}
+
+	var keys []string
+	switch v := value.(type) {
+	case string:
+		keys = []string{v}
+	case []string:
+		keys = v
+	default:
+		return nil, fmt.Errorf("keys.%s must be a key or an array of keys", action)
+	}
+	for i, key := range keys {
+		if utf8.RuneCountInString(key) > 1 {
+			key = strings.ToLower(key)
+			keys[i] = key
+		}
+		if !validKey(key) {
+			return nil, fmt.Errorf("unknown key %q for %s", key, action)
+		}
+	}
+	return keys, nil
+}
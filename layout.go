@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// textSpan is a run of text drawn in one style
+type textSpan struct {
+	text  string
+	style tcell.Style
+}
+
+// styledLine is one screen line of laid out text
+type styledLine struct {
+	spans []textSpan
+}
+
+// plainLine returns a line of text in a single style.
+func plainLine(text string, style tcell.Style) styledLine {
+	return styledLine{[]textSpan{{text, style}}}
+}
+
+// drawSpans draws a laid out line at x, y without wrapping.
+func drawSpans(screen tcell.Screen, x, y int, line styledLine) {
+	for _, span := range line.spans {
+		x = drawString(screen, x, y, span.text, span.style)
+	}
+}
+
+// wrapText splits text into lines that fit in width display columns,
+// breaking between words. Words wider than a line are broken between
+// grapheme clusters, and wrapped lines keep the indentation they started
+// with.
+func wrapText(text string, width int) []string {
+	var wrapped []string
+	if width < 1 {
+		width = 1
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		indent, rest := splitIndent(line)
+		words := strings.Fields(rest)
+		if len(words) == 0 {
+			wrapped = append(wrapped, "")
+			continue
+		}
+
+		// Very deep indentation would leave no room for the text
+		indentWidth := displayWidth(indent)
+		if indentWidth > width/2 {
+			indent, indentWidth = "", 0
+		}
+		available := width - indentWidth
+
+		currentLine := ""
+		currentWidth := 0
+		for _, word := range words {
+			wordWidth := displayWidth(word)
+			switch {
+			case currentWidth == 0 && wordWidth <= available:
+				currentLine, currentWidth = word, wordWidth
+			case currentWidth > 0 && currentWidth+1+wordWidth <= available:
+				currentLine += " " + word
+				currentWidth += 1 + wordWidth
+			case wordWidth <= available:
+				wrapped = append(wrapped, indent+currentLine)
+				currentLine, currentWidth = word, wordWidth
+			default:
+				// Break a word that does not fit on a line of its own
+				if currentWidth > 0 {
+					wrapped = append(wrapped, indent+currentLine)
+				}
+				pieces := hardWrap(word, available)
+				for _, piece := range pieces[:len(pieces)-1] {
+					wrapped = append(wrapped, indent+piece)
+				}
+				currentLine = pieces[len(pieces)-1]
+				currentWidth = displayWidth(currentLine)
+			}
+		}
+		if currentWidth > 0 {
+			wrapped = append(wrapped, indent+currentLine)
+		}
+	}
+
+	return wrapped
+}
+
+// appendWrapped lays out text at the given width and adds it to lines.
+func appendWrapped(lines []styledLine, text string, width int, style tcell.Style) []styledLine {
+	for _, line := range wrapText(strings.TrimRight(text, "\n"), width) {
+		lines = append(lines, plainLine(line, style))
+	}
+	return lines
+}
+
+// appendCardText lays out one side of a card, rendering its math and
+// Markdown unless raw mode is on. Images are fitted into height rows.
+func appendCardText(lines []styledLine, card *Flashcard, text string, width, height int) []styledLine {
+	if renderRaw {
+		return appendWrapped(lines, text, width, styleDefault)
+	}
+	text = renderMath(strings.TrimRight(text, "\n"))
+	return append(lines, renderMarkdown(text, card.dir, width, height)...)
+}
+
+// cardLayout divides the screen between the card's header, its scrollable
+// body and the prompts at the bottom
+type cardLayout struct {
+	body       []styledLine // Front and, once revealed, back of the card
+	backStart  int          // First body line of the back, -1 if hidden
+	bodyTop    int          // Screen row of the first body line
+	bodyHeight int          // Rows available to the body
+	footer     []styledLine // Prompts, drawn at the bottom of the screen
+	footerTop  int          // Screen row of the first footer line
+	buttons    []cardButton // Clickable parts of the footer
+	countdown  bool         // A countdown bar is drawn above the footer
+}
+
+// cardButton is a part of the footer that can be clicked instead of
+// pressing a key
+type cardButton struct {
+	line   int    // Footer line
+	x0, x1 int    // First column and the column after the button
+	action string // Key action a click stands for
+}
+
+// lineWidth returns the number of cells a laid out line takes up.
+func lineWidth(line styledLine) int {
+	width := 0
+	for _, span := range line.spans {
+		width += displayWidth(span.text)
+	}
+	return width
+}
+
+// addButton appends a button to a footer line.
+func (l *cardLayout) addButton(line *styledLine, index int, text string, style tcell.Style, action string) {
+	x0 := lineWidth(*line)
+	line.spans = append(line.spans, textSpan{text, style})
+	l.buttons = append(l.buttons, cardButton{index, x0, x0 + displayWidth(text), action})
+}
+
+// buttonAt returns the action of the button at x, y on the screen, or "".
+func (l cardLayout) buttonAt(x, y int) string {
+	for _, b := range l.buttons {
+		if y == l.footerTop+b.line && x >= b.x0 && x < b.x1 {
+			return b.action
+		}
+	}
+	return ""
+}
+
+// maxScroll returns the largest useful scroll offset of the body.
+func (l cardLayout) maxScroll() int {
+	if len(l.body) <= l.bodyHeight {
+		return 0
+	}
+	return len(l.body) - l.bodyHeight
+}
+
+// layoutCard computes where each part of a card goes on a screen of the
+// given size.
+func layoutCard(card *Flashcard, view cardView, width, height int) cardLayout {
+	l := cardLayout{backStart: -1, bodyTop: 2}
+
+	switch {
+	case view.footer != nil:
+		for _, text := range view.footer {
+			l.footer = append(l.footer, plainLine(text, stylePrompt))
+		}
+	case !view.revealed:
+		l.countdown = view.countdown > 0
+		l.footer = []styledLine{
+			plainLine(fmt.Sprintf("Press %s to see back, %s to quit", keyLabel("reveal"), keyLabel("quit")), stylePrompt),
+			cardKeysLine(),
+		}
+	case view.graded && view.correct:
+		l.footer = []styledLine{plainLine("✓ Correct", styleCorrect)}
+	case view.graded:
+		l.footer = []styledLine{plainLine("✗ Wrong", styleWrong)}
+	case view.timedOut:
+		l.footer = []styledLine{
+			plainLine(fmt.Sprintf("Time's up! Press any key to continue (%s to quit)", keyLabel("quit")), styleWrong),
+		}
+	default:
+		prompt := plainLine("Did you get it right? ", stylePrompt)
+		l.addButton(&prompt, 0, fmt.Sprintf(" ✓ Yes (%s) ", keyLabel("correct")), styleCorrect.Reverse(true), "correct")
+		prompt.spans = append(prompt.spans, textSpan{" ", stylePrompt})
+		l.addButton(&prompt, 0, fmt.Sprintf(" ✗ No (%s) ", keyLabel("wrong")), styleWrong.Reverse(true), "wrong")
+		prompt.spans = append(prompt.spans, textSpan{fmt.Sprintf(" (%s to quit)", keyLabel("quit")), stylePrompt})
+		l.footer = []styledLine{prompt, cardKeysLine()}
+	}
+
+	if view.message != "" {
+		l.footer = append([]styledLine{plainLine(view.message, styleScore)}, l.footer...)
+		for i := range l.buttons {
+			l.buttons[i].line++
+		}
+	}
+
+	// Leave a blank line between the body and the footer
+	footerHeight := len(l.footer) + 1
+	if l.countdown {
+		footerHeight++
+	}
+	l.footerTop = height - len(l.footer)
+	l.bodyHeight = height - l.bodyTop - footerHeight
+	if l.bodyHeight < 1 {
+		l.bodyHeight = 1
+	}
+
+	l.body = appendCardText(l.body, card, card.Front, width, l.bodyHeight)
+	if view.revealed {
+		l.body = append(l.body, styledLine{})
+		l.backStart = len(l.body)
+		l.body = append(l.body, plainLine("Back:", styleTitle), styledLine{})
+		l.body = appendCardText(l.body, card, card.Back, width, l.bodyHeight)
+	}
+
+	if len(l.body) > l.bodyHeight {
+		last := &l.footer[len(l.footer)-1]
+		hint := fmt.Sprintf(", %s/%s %s/%s scroll",
+			keyLabel("down"), keyLabel("up"), keyLabel("page-up"), keyLabel("page-down"))
+		last.spans = append(last.spans, textSpan{hint, stylePrompt})
+	}
+	return l
+}
+
+func drawCard(screen tcell.Screen, card *Flashcard, view *cardView) cardLayout {
+	screen.Clear()
+	width, height := screen.Size()
+	l := layoutCard(card, *view, width, height)
+
+	// Keep the scroll offset within the body
+	if view.scroll > l.maxScroll() {
+		view.scroll = l.maxScroll()
+	}
+	if view.scroll < 0 {
+		view.scroll = 0
+	}
+
+	// Header
+	header := plainLine("Front:", styleTitle)
+	if card.Flagged {
+		header.spans = append(header.spans, textSpan{" ★ flagged", stylePrompt})
+	}
+	if card.isLeech() {
+		header.spans = append(header.spans, textSpan{fmt.Sprintf(" Leech: failed %d times", len(card.failDates())), styleWrong})
+	}
+	if view.label != "" {
+		header.spans = append(header.spans, textSpan{" " + view.label, styleScore})
+	}
+	drawSpans(screen, 0, 0, header)
+	if l.maxScroll() > 0 {
+		last := view.scroll + l.bodyHeight
+		if last > len(l.body) {
+			last = len(l.body)
+		}
+		position := fmt.Sprintf("[%d-%d/%d]", view.scroll+1, last, len(l.body))
+		drawText(screen, width-len(position), 0, position, stylePrompt)
+	}
+
+	// Body
+	for i := 0; i < l.bodyHeight && view.scroll+i < len(l.body); i++ {
+		drawSpans(screen, 0, l.bodyTop+i, l.body[view.scroll+i])
+	}
+
+	// Footer
+	if l.countdown {
+		drawCountdown(screen, l.footerTop-1, view.remaining, view.countdown)
+	}
+	for i, line := range l.footer {
+		drawSpans(screen, 0, l.footerTop+i, line)
+	}
+
+	screen.Show()
+	return l
+}
+
+// cardKeysLine lists the keys that work on either side of a card.
+func cardKeysLine() styledLine {
+	return plainLine(fmt.Sprintf("%s suspend, %s bury until tomorrow, %s flag, %s raw/markdown, %s help",
+		keyLabel("suspend"), keyLabel("bury"), keyLabel("flag"), keyLabel("raw"), keyLabel("help")), stylePrompt)
+}
+
+// scrollKey returns how far a key scrolls a view of the given height,
+// or 0 if it is not a scroll key.
+func scrollKey(ev *tcell.EventKey, height int) int {
+	page := height - 1
+	if page < 1 {
+		page = 1
+	}
+	switch {
+	case keyIs(ev, "up"):
+		return -1
+	case keyIs(ev, "down"):
+		return 1
+	case keyIs(ev, "page-up"):
+		return -page
+	case keyIs(ev, "page-down"):
+		return page
+	}
+	return 0
+}
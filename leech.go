@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const (
+	leechTag              = "leech"
+	defaultLeechThreshold = 8
+)
+
+// leechThreshold returns the number of failed reviews after which a card
+// counts as a leech. Set per deck with "leech-threshold: N" between @@@;
+// values below 1 are ignored.
+func (ff *FlashFile) leechThreshold() int {
+	if n := ff.intOption("leech-threshold", defaultLeechThreshold); n >= 1 {
+		return n
+	}
+	return defaultLeechThreshold
+}
+
+// leechAction returns what happens to a new leech: "tag" (default) only
+// tags it, "suspend" also suspends it. Set with "leech-action: suspend".
+func (ff *FlashFile) leechAction() string {
+	return ff.stringOption("leech-action", "tag")
+}
+
+// failDates returns the dates of every failed review of the card.
+func (card *Flashcard) failDates() []string {
+	var dates []string
+	for _, review := range strings.Split(card.Reviewed, "\n") {
+		if strings.HasSuffix(review, "N") {
+			dates = append(dates, strings.TrimSpace(strings.TrimSuffix(review, "N")))
+		}
+	}
+	return dates
+}
+
+// isLeech reports whether the card has been marked as a leech.
+func (card *Flashcard) isLeech() bool {
+	return card.hasTag(leechTag)
+}
+
+// applyLeech marks the card as a leech once its fail count reaches the
+// deck's threshold. It returns true if the card became a leech just now.
+func applyLeech(ff *FlashFile, card *Flashcard) bool {
+	if card.isLeech() || len(card.failDates()) < ff.leechThreshold() {
+		return false
+	}
+	card.addTag(leechTag)
+	if ff.leechAction() == "suspend" {
+		card.Suspended = true
+	}
+	return true
+}
+
+// leechMessage explains what happened to a card that just became a leech.
+func leechMessage(card *Flashcard) string {
+	message := fmt.Sprintf("This card has been failed %d times and is now tagged %q.", len(card.failDates()), leechTag)
+	if card.Suspended {
+		message += " It has been suspended."
+	}
+	return message
+}
+
+// showLeech tells the user that a card has just turned into a leech.
+func showLeech(screen tcell.Screen, card *Flashcard) {
+	for {
+		screen.Clear()
+		drawText(screen, 0, 0, "Leech detected", styleWrong)
+		drawText(screen, 0, 2, card.Front, styleDefault)
+		drawText(screen, 0, 9, leechMessage(card), stylePrompt)
+		drawText(screen, 0, 11, "Consider rewriting it. Press any key to continue", stylePrompt)
+		screen.Show()
+
+		switch screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			return
+		}
+	}
+}
+
+// listLeeches prints every leech of each file with its fail count and dates.
+// With no files given, every deck findDecks finds is listed.
+func (a *app) listLeeches(filenames []string) error {
+	if len(filenames) == 0 {
+		var err error
+		filenames, err = findDecks()
+		if err != nil {
+			return err
+		}
+	}
+
+	found := 0
+	for _, filename := range filenames {
+		ff, err := parseFlashFile(filename)
+		if err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+
+		header := false
+		for i := range ff.Cards {
+			card := &ff.Cards[i]
+			fails := card.failDates()
+			if !card.isLeech() && len(fails) < ff.leechThreshold() {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(a.out, "%s (threshold %d)\n", filename, ff.leechThreshold())
+				header = true
+			}
+			status := ""
+			if card.Suspended {
+				status = " [suspended]"
+			}
+			fmt.Fprintf(a.out, "  %d. %s%s\n", i+1, firstLine(card.Front), status)
+			fmt.Fprintf(a.out, "     %d fails: %s\n", len(fails), strings.Join(fails, ", "))
+			found++
+		}
+	}
+
+	if found == 0 {
+		fmt.Fprintln(a.out, "No leeches")
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// errDeckLocked is returned by lockDeck when another flash process, such
+// as a review in the terminal or flash serve, is changing the deck.
+var errDeckLocked = errors.New("deck is in use by another flash")
+
+// lockPath returns the hidden file locked while a deck is being changed.
+func lockPath(filename string) string {
+	return filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".lock")
+}
+
+// lockDeck takes the lock that keeps two flash processes from changing a
+// deck at once. It does not wait: if the deck is locked it fails straight
+// away. The returned function releases the lock; calls after the first do
+// nothing, so that it can be deferred and still be called early.
+func lockDeck(filename string) (func(), error) {
+	unlock, err := lockFile(lockPath(filename))
+	if errors.Is(err, errDeckLocked) {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error locking %s: %v", filename, err)
+	}
+	return sync.OnceFunc(unlock), nil
+}
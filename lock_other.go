@@ -0,0 +1,24 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile creates path as the lock and removes it on release. A lock left
+// behind by a crash has to be removed by hand.
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, errDeckLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return func() {
+		os.Remove(path)
+	}, nil
+}
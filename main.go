@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -14,25 +15,42 @@ import (
 )
 
 type Flashcard struct {
-	Front    string
-	Back     string
-	Reviewed string
+	Front       string
+	Back        string
+	Reviewed    string
+	Suspended   bool   // Never shown in a review until unsuspended
+	BuriedUntil string // Hidden until this date (2006/01/02), empty if not buried
+	Flagged     bool   // Starred for later attention
+	Tags        []string
+
+	dir string // Directory of the deck, image paths are relative to it
 }
 
 type FlashFile struct {
 	Title    string
 	Stats    string
+	Options  map[string]string // Per-deck settings (between @@@)
 	Cards    []Flashcard
 	Filename string
 }
 
+// Styles the screens draw with, set from the theme by applyTheme
 var (
-	styleDefault = tcell.StyleDefault
-	styleTitle   = tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
-	stylePrompt  = tcell.StyleDefault.Foreground(tcell.ColorYellow)
-	styleScore   = tcell.StyleDefault.Foreground(tcell.NewRGBColor(0, 255, 255)) // Cyan
-	styleCorrect = tcell.StyleDefault.Foreground(tcell.ColorGreen)
-	styleWrong   = tcell.StyleDefault.Foreground(tcell.ColorRed)
+	styleDefault tcell.Style
+	styleTitle   tcell.Style
+	stylePrompt  tcell.Style
+	styleScore   tcell.Style
+	styleCorrect tcell.Style
+	styleWrong   tcell.Style
+	styleHeading tcell.Style
+	styleCode    tcell.Style
+	styleQuote   tcell.Style
+	styleKeyword tcell.Style
+	styleString  tcell.Style
+	styleComment tcell.Style
+	styleNumber  tcell.Style
+	styleType    tcell.Style
+	styleKey     tcell.Style
 )
 
 func parseFlashFile(filename string) (*FlashFile, error) {
@@ -81,6 +99,29 @@ func parseFlashFile(filename string) (*FlashFile, error) {
 	}
 	ff.Stats = strings.Join(statsLines, "\n")
 
+	// Parse options (between @@@), one "key: value" per line
+	inOptions := false
+	for _, line := range lines {
+		if line == "@@@" {
+			if !inOptions {
+				inOptions = true
+				continue
+			} else {
+				break
+			}
+		}
+		if inOptions {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			if ff.Options == nil {
+				ff.Options = map[string]string{}
+			}
+			ff.Options[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
 	// Parse cards
 	var currentCard Flashcard
 	inCard := false
@@ -94,6 +135,16 @@ func parseFlashFile(filename string) (*FlashFile, error) {
 				if len(reviewedLines) > 0 {
 					currentCard.Reviewed = strings.Join(reviewedLines, "\n")
 				}
+				// Blank lines inside the front and back are kept, the
+				// ones separating them from the markers are not
+				currentCard.Front = trimBlankLines(currentCard.Front)
+				currentCard.Back = trimBlankLines(currentCard.Back)
+				if currentCard.Front != "" {
+					currentCard.Front += "\n"
+				}
+				if currentCard.Back != "" {
+					currentCard.Back += "\n"
+				}
 				ff.Cards = append(ff.Cards, currentCard)
 				currentCard = Flashcard{}
 				reviewedLines = []string{} // Reset for next card
@@ -111,7 +162,11 @@ func parseFlashFile(filename string) (*FlashFile, error) {
 			case line == "!REVIEWED":
 				section = "reviewed"
 				reviewedLines = []string{} // Reset at start of reviewed section
-			case line != "":
+			case line == "!TAGS":
+				section = "tags"
+			case line == "!STATE":
+				section = "state"
+			default:
 				switch section {
 				case "front":
 					currentCard.Front += line + "\n"
@@ -121,11 +176,19 @@ func parseFlashFile(filename string) (*FlashFile, error) {
 					if line != "" {
 						reviewedLines = append(reviewedLines, line)
 					}
+				case "tags":
+					currentCard.Tags = append(currentCard.Tags, strings.Fields(line)...)
+				case "state":
+					parseCardState(&currentCard, line)
 				}
 			}
 		}
 	}
 
+	for i := range ff.Cards {
+		ff.Cards[i].dir = filepath.Dir(filename)
+	}
+
 	return &ff, nil
 }
 
@@ -145,15 +208,37 @@ func saveFlashFile(ff *FlashFile) error {
 	}
 	content.WriteString("&&&\n")
 
+	// Write options
+	if len(ff.Options) > 0 {
+		keys := make([]string, 0, len(ff.Options))
+		for key := range ff.Options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		content.WriteString("@@@\n")
+		for _, key := range keys {
+			content.WriteString(key + ": " + ff.Options[key] + "\n")
+		}
+		content.WriteString("@@@\n")
+	}
+
 	// Write cards
 	content.WriteString("***\n") // Start with ***
 	for i, card := range ff.Cards {
 		content.WriteString("\n!FRONT\n\n")
-		content.WriteString(strings.TrimSpace(card.Front))
+		content.WriteString(trimBlankLines(card.Front))
 		content.WriteString("\n\n!BACK\n\n")
-		content.WriteString(strings.TrimSpace(card.Back))
+		content.WriteString(trimBlankLines(card.Back))
+		if len(card.Tags) > 0 {
+			content.WriteString("\n\n!TAGS\n\n")
+			content.WriteString(strings.Join(card.Tags, " "))
+		}
 		content.WriteString("\n\n!REVIEWED\n\n")
 		content.WriteString(strings.TrimSpace(card.Reviewed))
+		if state := formatCardState(card); state != "" {
+			content.WriteString("\n\n!STATE\n\n")
+			content.WriteString(state)
+		}
 		content.WriteString("\n\n***\n") // End each card with ***
 		if i < len(ff.Cards)-1 {
 			content.WriteString("***\n") // Start next card with another ***
@@ -163,6 +248,19 @@ func saveFlashFile(ff *FlashFile) error {
 	return os.WriteFile(ff.Filename, []byte(content.String()), 0644)
 }
 
+// trimBlankLines removes leading and trailing blank lines from text while
+// keeping the indentation of its first line.
+func trimBlankLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
 func getPreviousScore(ff *FlashFile) string {
 	if ff.Stats == "" {
 		return "No previous scores"
@@ -186,346 +284,487 @@ func getPreviousScore(ff *FlashFile) string {
 	return strings.Join(scores, "\n")
 }
 
-// Add this helper function
+// findDecks returns the .flsh files in the current directory and the deck
+// directories from the config file.
+func findDecks() ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	for _, dir := range append([]string{"."}, cfg.DeckDirs...) {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.flsh"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			abs, err := filepath.Abs(match)
+			if err != nil || seen[abs] {
+				continue
+			}
+			seen[abs] = true
+			files = append(files, match)
+		}
+	}
+	if len(files) == 0 && len(cfg.DeckDirs) > 0 {
+		return nil, fmt.Errorf("no .flsh files found in current directory or deck directories")
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .flsh files found in current directory")
+	}
+	return files, nil
+}
+
+// findSingleFlashFile picks the deck for a command given none: the default
+// deck from the config file, or else the only deck found.
 func findSingleFlashFile() (string, error) {
-	files, err := filepath.Glob("*.flsh")
+	if cfg.DefaultDeck != "" {
+		return cfg.DefaultDeck, nil
+	}
+	files, err := findDecks()
 	if err != nil {
 		return "", err
 	}
-	if len(files) == 0 {
-		return "", fmt.Errorf("no .flsh files found in current directory")
-	}
 	if len(files) > 1 {
 		return "", fmt.Errorf("multiple .flsh files found, please specify which one to use")
 	}
 	return files[0], nil
 }
 
+// newScreen starts drawing on the terminal with the configured theme.
+func newScreen() (tcell.Screen, error) {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return nil, err
+	}
+	if err := screen.Init(); err != nil {
+		return nil, err
+	}
+	if err := applyTheme(cfg.Theme, cfg.Colors, screen.Colors()); err != nil {
+		screen.Fini()
+		return nil, err
+	}
+	screen.SetStyle(styleDefault)
+	if cfg.Mouse {
+		screen.EnableMouse()
+	}
+	return screen, nil
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		// Show file selection menu
-		files, err := filepath.Glob("*.flsh")
-		if err != nil || len(files) == 0 {
-			fmt.Println("Usage:")
-			fmt.Println("  Review all cards: flash file.flsh")
-			fmt.Println("  Review wrong cards: flash review file.flsh")
-			fmt.Println("  Add card: flash add file.flsh")
-			fmt.Println("  Create new file: flash new <name>")
-			os.Exit(1)
-		}
-
-		// Initialize screen for file selection
-		screen, err := tcell.NewScreen()
-		if err != nil {
-			log.Fatal(err)
-		}
-		if err := screen.Init(); err != nil {
-			log.Fatal(err)
-		}
-		defer screen.Fini()
+	os.Exit(newApp().run(os.Args[1:]))
+}
 
-		// Load all flash files
-		var flashFiles []FlashFile
-		for _, f := range files {
-			ff, err := parseFlashFile(f)
-			if err != nil {
-				log.Printf("Error reading %s: %v\n", f, err)
-				continue
-			}
-			flashFiles = append(flashFiles, *ff)
-		}
+// pickDeck shows the decks findDecks finds and reviews the one selected.
+func (a *app) pickDeck() error {
+	files, err := findDecks()
+	if err != nil {
+		return err
+	}
 
-		if len(flashFiles) == 0 {
-			fmt.Println("No valid .flsh files found")
-			os.Exit(1)
+	// Load all flash files
+	var flashFiles []FlashFile
+	for _, f := range files {
+		ff, err := parseFlashFile(f)
+		if err != nil {
+			log.Printf("Error reading %s: %v\n", f, err)
+			continue
 		}
+		flashFiles = append(flashFiles, *ff)
+	}
 
-		selected := showFileSelection(screen, flashFiles)
-		if selected == nil {
-			return
-		}
-		// Instead of modifying os.Args, handle the selected file directly
-		handleRegularReview(selected)
-		return
+	if len(flashFiles) == 0 {
+		return fmt.Errorf("no valid .flsh files found")
 	}
 
-	// Check command type first
-	switch os.Args[1] {
-	case "new":
-		if len(os.Args) != 3 {
-			fmt.Println("Usage: flash new <name>")
-			fmt.Println("Creates a new flashcard file (will add .flsh extension if not present)")
-			os.Exit(1)
-		}
-		err := createNewFlashFile(os.Args[2])
-		if err != nil {
-			log.Fatal(err)
-		}
-		return
-	case "add":
-		filename := ""
-		if len(os.Args) > 2 {
-			filename = os.Args[2]
-		} else {
-			var err error
-			filename, err = findSingleFlashFile()
-			if err != nil {
-				fmt.Println("Usage: flash add file.flsh")
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
-			}
-		}
-		err := addFlashcard(filename)
+	// Stepping back from a deck's title page returns to the picker
+	opts := defaultReviewOptions()
+	opts.Picked = true
+	for {
+		screen, err := a.openScreen()
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		return
-	case "review":
-		filename := ""
-		if len(os.Args) > 2 {
-			filename = os.Args[2]
-		} else {
-			var err error
-			filename, err = findSingleFlashFile()
-			if err != nil {
-				fmt.Println("Usage: flash review file.flsh")
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
-			}
+		selected := showFileSelection(screen, flashFiles)
+		screen.Fini()
+		if selected == nil {
+			return nil
 		}
-		err := reviewWrongCards(filename)
-		if err != nil {
-			log.Fatal(err)
+		err = a.runSession(selected, dueMode(), opts)
+		if !errors.Is(err, errBack) {
+			return err
 		}
-		return
 	}
+}
 
-	// Handle regular review (no command)
-	var filename string
-	if filepath.Ext(os.Args[1]) == ".flsh" {
-		filename = os.Args[1]
-	} else {
-		var err error
-		filename, err = findSingleFlashFile()
-		if err != nil {
-			fmt.Println("Usage:")
-			fmt.Println("  Review all cards: flash file.flsh")
-			fmt.Println("  Review wrong cards: flash review file.flsh")
-			fmt.Println("  Add card: flash add file.flsh")
-			fmt.Println("  Create new file: flash new <name>")
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+// showTitlePage shows a deck's title until the user continues, steps back
+// or quits.
+func showTitlePage(screen tcell.Screen, ff *FlashFile) navResult {
+	draw := func() {
+		screen.Clear()
+
+		// Draw title
+		titleLines := strings.Split(ff.Title, "\n")
+		for i, line := range titleLines {
+			drawText(screen, 0, i, line, styleTitle)
 		}
-	}
 
-	// Handle direct file path or show file selection
-	var selectedFile *FlashFile
-	ff, err := parseFlashFile(filename)
-	if err != nil {
-		log.Printf("Error reading %s: %v\n", filename, err)
-		os.Exit(1)
+		drawText(screen, 0, len(titleLines)+2, fmt.Sprintf("Press %s to continue, %s to quit",
+			keyLabel("confirm"), keyLabel("quit")), stylePrompt)
+		screen.Show()
 	}
-	selectedFile = ff
+	draw()
 
-	// Initialize screen for flashcard review
-	screen, err := tcell.NewScreen()
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := screen.Init(); err != nil {
-		log.Fatal(err)
+	for {
+		ev := screen.PollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+			draw()
+		case *tcell.EventKey:
+			switch {
+			case keyIs(ev, "quit"):
+				return navQuit
+			case keyIs(ev, "back"):
+				return navBack
+			case keyIs(ev, "confirm"):
+				return navNext
+			}
+		}
 	}
-	screen.Clear()
-	defer screen.Fini()
+}
 
-	// Show title page
-	if !showTitlePage(screen, selectedFile) {
-		return // User quit
+func showFileSelection(screen tcell.Screen, files []FlashFile) *FlashFile {
+	cursor, top := 0, 0
+	message := ""
+	var rowFile []int // File drawn on each screen row, -1 for none
+
+	// entryHeight is the number of rows a file takes, with the blank line
+	// after it
+	entryHeight := func(i int) int {
+		return len(strings.Split(files[i].Title, "\n")) + 1
 	}
 
-	// Run through flashcards
-	correct := 0
-	total := 0
+	draw := func() {
+		screen.Clear()
+		_, height := screen.Size()
+
+		// Calculate the width of the number prefix (e.g., "1. ")
+		prefixWidth := len(fmt.Sprint(len(files))) + 2
+		listHeight := height - 2 // Leave room for the prompt and messages
 
-	for i := range selectedFile.Cards {
-		if showCard(screen, &selectedFile.Cards[i]) {
-			// User quit early
-			break
+		// Scroll so the highlighted file is on screen
+		if cursor < top {
+			top = cursor
 		}
-		total++
-		if strings.HasSuffix(selectedFile.Cards[i].Reviewed, "Y") {
-			correct++
+		for top < cursor {
+			rows := 0
+			for i := top; i <= cursor; i++ {
+				rows += entryHeight(i)
+			}
+			if rows <= listHeight {
+				break
+			}
+			top++
 		}
-	}
 
-	if total > 0 {
-		// Update stats with timestamp
-		currentTime := time.Now().Format("2006/01/02 15:04")
-		newScore := fmt.Sprintf("%s    %d/%d", currentTime, correct, total)
-		if selectedFile.Stats != "" {
-			selectedFile.Stats += "\n"
+		rowFile = make([]int, height)
+		for y := range rowFile {
+			rowFile[y] = -1
 		}
-		selectedFile.Stats += newScore
-
-		// Display score comparison in UI
-		screen.Clear()
-		drawText(screen, 0, 0, "Current score:", styleTitle)
-		drawText(screen, 0, 1, newScore, styleScore)
-		drawText(screen, 0, 3, "Previous scores:", styleTitle)
 
-		// Get previous scores and count lines
-		prevScores := getPreviousScore(selectedFile)
-		scoreLines := strings.Split(prevScores, "\n")
-		numPrevScoreLines := len(scoreLines)
-
-		// Draw scores and graph side by side
-		drawText(screen, 0, 4, prevScores, styleScore)
-		drawScoreGraph(screen, 40, 4, scoreLines, 30, 10)
+		currentY := 0
+		for i := top; i < len(files) && currentY < listHeight; i++ {
+			style := styleTitle
+			if i == cursor {
+				style = style.Reverse(true)
+			}
 
-		drawText(screen, 0, 6+numPrevScoreLines, "Press any key to exit", stylePrompt)
-		screen.Show()
+			// Draw the file number
+			drawText(screen, 0, currentY, fmt.Sprintf("%d.", i+1), style)
 
-		// Wait for keypress and save
-		for {
-			ev := screen.PollEvent()
-			switch ev.(type) {
-			case *tcell.EventKey:
-				err = saveFlashFile(selectedFile)
-				if err != nil {
-					log.Fatal(err)
+			// Split title into lines and draw each line with proper indentation
+			for j, line := range strings.Split(files[i].Title, "\n") {
+				drawText(screen, prefixWidth, currentY+j, line, style)
+				if currentY+j < height {
+					rowFile[currentY+j] = i
 				}
-				fmt.Printf("%d/%d\n", correct, total)
-				return
 			}
+			currentY += entryHeight(i) // Add space between files
 		}
-	}
-}
 
-func showTitlePage(screen tcell.Screen, ff *FlashFile) bool {
-	screen.Clear()
-
-	// Draw title
-	titleLines := strings.Split(ff.Title, "\n")
-	for i, line := range titleLines {
-		drawText(screen, 0, i, line, styleTitle)
+		drawText(screen, 0, height-2, fmt.Sprintf("Select a file (1-9, or %s/%s and %s), %s for help, %s to quit",
+			keyLabel("down"), keyLabel("up"), keyLabel("confirm"), keyLabel("help"), keyLabel("quit")), stylePrompt)
+		if message != "" {
+			drawText(screen, 0, height-1, message, styleWrong)
+		}
+		screen.Show()
 	}
-
-	drawText(screen, 0, len(titleLines)+2, "Press ENTER to continue, q to quit", stylePrompt)
-	screen.Show()
+	draw()
 
 	for {
 		ev := screen.PollEvent()
 		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventMouse:
+			_, y, clicked := mouseClick(ev)
+			if clicked && y < len(rowFile) && rowFile[y] >= 0 {
+				return &files[rowFile[y]]
+			}
+			// The wheel moves the highlight one file at a time
+			switch delta := wheelScroll(ev); {
+			case delta < 0:
+				cursor = max(cursor-1, 0)
+			case delta > 0:
+				cursor = min(cursor+1, len(files)-1)
+			}
 		case *tcell.EventKey:
-			if ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' {
-				return false
+			message = ""
+			_, height := screen.Size()
+			if moved, ok := moveCursor(ev, cursor, len(files), height/3); ok {
+				cursor = moved
+				break
 			}
-			if ev.Key() == tcell.KeyEnter {
-				return true
+			switch {
+			case keyIs(ev, "quit"):
+				return nil
+			case keyIs(ev, "confirm"):
+				return &files[cursor]
+			case keyIs(ev, "help"):
+				showHelp(screen, pickerActions)
+			case keyIs(ev, "command"):
+				var cmd screenCommand
+				cmd, message = promptCommand(screen)
+				switch {
+				case cmd.name == "":
+				case cmd.name == "quit":
+					return nil
+				case cmd.name == "goto" && cmd.line <= len(files):
+					return &files[cmd.line-1]
+				case cmd.name == "goto":
+					message = fmt.Sprintf("There are only %d files", len(files))
+				default:
+					message = fmt.Sprintf(":%s works on cards, not files", cmd.name)
+				}
+			case ev.Rune() >= '1' && ev.Rune() <= '9':
+				idx := int(ev.Rune() - '1')
+				if idx < len(files) {
+					return &files[idx]
+				}
 			}
 		}
+		draw()
 	}
 }
 
-func showFileSelection(screen tcell.Screen, files []FlashFile) *FlashFile {
-	screen.Clear()
+// cardResult describes how a card presented by showCard was left
+type cardResult int
 
-	// Calculate the width of the number prefix (e.g., "1. ")
-	prefixWidth := 3 // Width of "X. " where X is the number
-	currentY := 0
+const (
+	cardCorrect cardResult = iota
+	cardWrong
+	cardSkipped // Suspended or buried without grading
+	cardBack    // The user stepped back to the previous screen
+	cardQuit
+)
 
-	for i, file := range files {
-		// Draw the file number
-		drawText(screen, 0, currentY, fmt.Sprintf("%d.", i+1), styleTitle)
+// showCard presents a card and waits for a grade. With a countdown, the
+// front is shown for at most that long before the back is revealed and the
+// card is graded wrong. It also returns how long it took to reveal the back,
+// and whether the card was changed without being graded, such as flagged.
+func showCard(screen tcell.Screen, card *Flashcard, countdown time.Duration) (cardResult, time.Duration, bool) {
+	view := cardView{countdown: countdown}
+	start := time.Now()
+	var latency time.Duration
+	changed := false
+
+	if countdown > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go tickCountdown(screen, stop)
+	}
 
-		// Split title into lines and draw each line with proper indentation
-		titleLines := strings.Split(file.Title, "\n")
-		for j, line := range titleLines {
-			if j == 0 {
-				// First line comes after the number
-				drawText(screen, prefixWidth, currentY, line, styleTitle)
-			} else {
-				// Subsequent lines are indented to match
-				drawText(screen, prefixWidth, currentY+j, line, styleTitle)
-			}
+	// grade shows the grade in its color for a moment before moving on
+	grade := func(correct bool) (cardResult, time.Duration, bool) {
+		view.graded = true
+		view.correct = correct
+		drawCard(screen, card, &view)
+		time.Sleep(feedbackDelay)
+		if correct {
+			return cardCorrect, latency, changed
 		}
-		currentY += len(titleLines) + 1 // Add space between files
+		return cardWrong, latency, changed
 	}
 
-	drawText(screen, prefixWidth, currentY, "Select a file (1-9) or press 'q' to quit:", stylePrompt)
-	screen.Show()
+	// reveal shows the back, scrolled into view if the card is long
+	reveal := func() {
+		view.revealed = true
+		width, height := screen.Size()
+		l := layoutCard(card, view, width, height)
+		if l.maxScroll() > 0 {
+			view.scroll = l.backStart
+		}
+	}
 
 	for {
+		view.remaining = countdown - time.Since(start)
+		layout := drawCard(screen, card, &view)
+
 		ev := screen.PollEvent()
 		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventInterrupt:
+			// Countdown tick, reveal the card once time is up
+			if countdown > 0 && !view.revealed && time.Since(start) >= countdown {
+				view.timedOut = true
+				reveal()
+				latency = countdown
+			}
+		case *tcell.EventMouse:
+			// Click to reveal, then on the buttons to grade
+			x, y, clicked := mouseClick(ev)
+			view.scroll += wheelScroll(ev)
+			switch {
+			case !clicked:
+			case view.timedOut:
+				return cardWrong, latency, changed
+			case !view.revealed:
+				reveal()
+				latency = time.Since(start)
+			default:
+				if action := layout.buttonAt(x, y); action != "" {
+					return grade(action == "correct")
+				}
+			}
 		case *tcell.EventKey:
-			if ev.Rune() == 'q' {
-				return nil
+			view.message = ""
+			if keyIs(ev, "quit") {
+				return cardQuit, latency, changed
 			}
-			if ev.Rune() >= '1' && ev.Rune() <= '9' {
-				idx := int(ev.Rune() - '1')
-				if idx < len(files) {
-					return &files[idx]
+			if keyIs(ev, "back") {
+				return cardBack, latency, changed
+			}
+
+			// Scroll long cards
+			if delta := scrollKey(ev, layout.bodyHeight); delta != 0 {
+				view.scroll += delta
+				continue
+			}
+
+			// Help and commands work at any time
+			if keyIs(ev, "help") {
+				showHelp(screen, reviewActions)
+				continue
+			}
+			if keyIs(ev, "command") {
+				var cmd screenCommand
+				cmd, view.message = promptCommand(screen)
+				switch cmd.name {
+				case "quit":
+					return cardQuit, latency, changed
+				case "tag":
+					card.addTag(cmd.tag)
+					changed = true
+					view.message = "Tagged " + cmd.tag
+				case "goto":
+					view.message = ":goto works when browsing a deck"
+				}
+				continue
+			}
+
+			// Out of time, any key moves on
+			if view.timedOut {
+				return cardWrong, latency, changed
+			}
+
+			// Card state keys work on both sides of the card
+			switch {
+			case keyIs(ev, "suspend"):
+				card.Suspended = true
+				return cardSkipped, latency, changed
+			case keyIs(ev, "bury"):
+				card.bury(time.Now())
+				return cardSkipped, latency, changed
+			case keyIs(ev, "flag"):
+				card.Flagged = !card.Flagged
+				changed = true
+				continue
+			case keyIs(ev, "raw"):
+				renderRaw = !renderRaw
+				continue
+			}
+
+			// Wait for space
+			if !view.revealed {
+				if keyIs(ev, "reveal") {
+					reveal()
+					latency = time.Since(start)
 				}
+				continue
+			}
+
+			// Wait for y/n
+			if keyIs(ev, "correct") || keyIs(ev, "wrong") {
+				return grade(keyIs(ev, "correct"))
 			}
 		}
 	}
 }
 
-func showCard(screen tcell.Screen, card *Flashcard) bool {
-	screen.Clear()
+// feedbackDelay is how long the grade of a card is shown before moving on
+var feedbackDelay = 400 * time.Millisecond
+
+// cardView is what drawCard needs to know about the state of showCard
+type cardView struct {
+	revealed  bool
+	timedOut  bool
+	graded    bool // The card was just graded, correct says how
+	correct   bool
+	countdown time.Duration // Time allowed for the front, 0 if untimed
+	remaining time.Duration
+	scroll    int      // First body line shown
+	label     string   // Extra header text, such as the card's position
+	message   string   // Shown above the footer until the next key
+	footer    []string // Prompts replacing the review ones, for other screens
+}
 
-	// Show front
-	drawText(screen, 0, 0, "Front:", styleTitle)
-	drawText(screen, 0, 2, card.Front, styleDefault)
-	drawText(screen, 0, 15, "Press SPACE to see back, q to quit", stylePrompt)
-	screen.Show()
+// drawCountdown draws the time left to answer as a bar across the screen
+func drawCountdown(screen tcell.Screen, y int, remaining, total time.Duration) {
+	if remaining < 0 {
+		remaining = 0
+	}
+	width, _ := screen.Size()
+	label := fmt.Sprintf(" %.1fs", remaining.Seconds())
+	barWidth := width - len(label)
+	if barWidth < 1 {
+		barWidth = 1
+	}
+	filled := int(int64(barWidth) * int64(remaining) / int64(total))
 
-	// Wait for space
-	for {
-		ev := screen.PollEvent()
-		switch ev := ev.(type) {
-		case *tcell.EventKey:
-			if ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' {
-				return true
-			}
-			if ev.Key() == tcell.KeyRune && ev.Rune() == ' ' || ev.Key() == tcell.KeyEnter {
-				goto showBack
-			}
+	style := stylePrompt
+	if remaining < total/4 {
+		style = styleWrong
+	}
+	for i := 0; i < barWidth; i++ {
+		r := '░'
+		if i < filled {
+			r = '█'
 		}
+		screen.SetContent(i, y, r, nil, style)
 	}
+	drawText(screen, barWidth, y, label, style)
+}
 
-showBack:
-	screen.Clear()
-	drawText(screen, 0, 0, "Front:", styleTitle)
-	drawText(screen, 0, 2, card.Front, styleDefault)
-	drawText(screen, 0, 8, "Back:", styleTitle)
-	drawText(screen, 0, 10, card.Back, styleDefault)
-	drawText(screen, 0, 16, "Did you get it right? (y/n) (q to quit)", stylePrompt)
-	screen.Show()
-
-	// Wait for y/n
+// tickCountdown wakes up showCard regularly to redraw the countdown until
+// stop is closed.
+func tickCountdown(screen tcell.Screen, stop <-chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
 	for {
-		ev := screen.PollEvent()
-		switch ev := ev.(type) {
-		case *tcell.EventKey:
-			if ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' {
-				return true
-			}
-			if ev.Rune() == 'y' || ev.Rune() == 'Y' {
-				if card.Reviewed != "" {
-					card.Reviewed += "\n"
-				}
-				card.Reviewed += time.Now().Format("2006/01/02") + " Y"
-				return false
-			}
-			if ev.Rune() == 'n' || ev.Rune() == 'N' {
-				if card.Reviewed != "" {
-					card.Reviewed += "\n"
-				}
-				card.Reviewed += time.Now().Format("2006/01/02") + " N"
-				return false
-			}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			screen.PostEvent(tcell.NewEventInterrupt(nil))
 		}
 	}
 }
@@ -534,50 +773,92 @@ func drawText(screen tcell.Screen, x, y int, text string, style tcell.Style) {
 	width, _ := screen.Size()
 	maxWidth := width - x
 
-	lines := strings.Split(text, "\n")
-	currentY := y
+	for i, line := range wrapText(text, maxWidth) {
+		drawString(screen, x, y+i, line, style)
+	}
+}
 
-	for _, line := range lines {
-		if line == "" {
-			currentY++
-			continue
+// showScoreScreen shows the score of a session next to the previous ones
+// and waits for a key. Clicking a point of the graph shows that session.
+func showScoreScreen(screen tcell.Screen, ff *FlashFile, newScore, remaining string) {
+	const graphHeight = 10
+
+	// Get previous scores and count lines
+	prevScores := getPreviousScore(ff)
+	scoreLines := strings.Split(prevScores, "\n")
+	numPrevScoreLines := len(scoreLines)
+	details := ""
+
+	for {
+		// Display score comparison in UI
+		screen.Clear()
+		drawText(screen, 0, 0, "Current score:", styleTitle)
+		drawText(screen, 0, 1, newScore, styleScore)
+		drawText(screen, 0, 3, "Previous scores:", styleTitle)
+
+		// Draw scores and graph side by side
+		drawText(screen, 0, 4, prevScores, styleScore)
+		points := drawScoreGraph(screen, 40, 4, scoreLines, 30, graphHeight)
+		if details != "" {
+			drawText(screen, 40, 5+graphHeight, details, styleTitle)
 		}
 
-		words := strings.Fields(line)
-		if len(words) == 0 {
-			currentY++
-			continue
+		// Show what a session limit left out, below the scores and the
+		// graph with its details line
+		promptY := 6 + numPrevScoreLines
+		if len(points) > 0 {
+			promptY = max(promptY, 7+graphHeight)
+		}
+		if remaining != "" {
+			drawText(screen, 0, promptY, remaining, stylePrompt)
+			promptY += strings.Count(remaining, "\n") + 2
 		}
 
-		currentLine := words[0]
+		prompt := "Press any key to exit"
+		if cfg.Mouse && len(points) > 0 {
+			prompt += ", click a point of the graph for details"
+		}
+		drawText(screen, 0, promptY, prompt, stylePrompt)
+		screen.Show()
 
-		for _, word := range words[1:] {
-			if len(currentLine)+1+len(word) < maxWidth {
-				currentLine += " " + word
-			} else {
-				for i, r := range currentLine {
-					screen.SetContent(x+i, currentY, r, nil, style)
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventMouse:
+			x, y, clicked := mouseClick(ev)
+			if !clicked {
+				break
+			}
+			for _, p := range points {
+				if abs(p.x-x) <= 1 && abs(p.y-y) <= 1 {
+					details = fmt.Sprintf("%s: %s correct (%.0f%%)", p.date, p.score, p.percent)
+					break
 				}
-				currentY++
-				currentLine = word
 			}
+		case *tcell.EventKey:
+			return
 		}
-
-		for i, r := range currentLine {
-			screen.SetContent(x+i, currentY, r, nil, style)
-		}
-		currentY++
 	}
 }
 
-// Add this new function to draw the graph
-func drawScoreGraph(screen tcell.Screen, x, y int, scores []string, width, height int) {
+// graphPoint is where a session was drawn on the score graph
+type graphPoint struct {
+	x, y    int
+	date    string
+	score   string // Correct/total
+	percent float64
+}
+
+// drawScoreGraph draws the scores from newest to oldest as a line graph
+// and returns where each session's point is.
+func drawScoreGraph(screen tcell.Screen, x, y int, scores []string, width, height int) []graphPoint {
 	if len(scores) < 2 {
-		return
+		return nil
 	}
 
 	// Parse scores into numbers (in reverse order to show oldest to newest)
 	var numbers []float64
+	var sessions []graphPoint
 	for i := len(scores) - 1; i >= 0; i-- { // Changed this line to reverse the order
 		score := scores[i]
 		parts := strings.Split(score, "    ")
@@ -597,6 +878,10 @@ func drawScoreGraph(screen tcell.Screen, x, y int, scores []string, width, heigh
 			continue
 		}
 		numbers = append(numbers, num/den*100) // Convert to percentage
+		sessions = append(sessions, graphPoint{date: strings.TrimSpace(parts[0]), score: parts[1], percent: num / den * 100})
+	}
+	if len(numbers) < 2 {
+		return nil
 	}
 
 	// Find min and max
@@ -631,6 +916,7 @@ func drawScoreGraph(screen tcell.Screen, x, y int, scores []string, width, heigh
 
 		// Draw point
 		screen.SetContent(px, py, '●', nil, styleScore)
+		sessions[i].x, sessions[i].y = px, py
 
 		// Draw line from last point
 		if lastX != -1 {
@@ -644,6 +930,7 @@ func drawScoreGraph(screen tcell.Screen, x, y int, scores []string, width, heigh
 	minStr := fmt.Sprintf("%.0f%%", min)
 	drawText(screen, x-len(maxStr)-1, y, maxStr, styleScore)
 	drawText(screen, x-len(minStr)-1, y+height-2, minStr, styleScore)
+	return sessions
 }
 
 // Add this helper function to draw lines
@@ -707,32 +994,33 @@ func getMultilineInput(screen tcell.Screen, startY int, prompt string, bottomPro
 
 	for {
 		// Clear input area and redraw all lines
+		width, _ := screen.Size()
 		for i := startY; i < height-1; i++ {
-			for j := 0; j < 80; j++ {
+			for j := 0; j < width; j++ {
 				screen.SetContent(j, i, ' ', nil, styleDefault)
 			}
 		}
 
 		// Draw previous lines
 		for i, line := range lines {
-			for j, r := range line {
-				screen.SetContent(j, startY+i, r, nil, styleDefault)
-			}
+			drawString(screen, 0, startY+i, line, styleDefault)
 		}
 
 		// Draw current line
-		for i, r := range currentLine {
-			screen.SetContent(i, y, r, nil, styleDefault)
-		}
+		drawString(screen, 0, y, currentLine, styleDefault)
 
 		screen.Show()
 
 		ev := screen.PollEvent()
 		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
 		case *tcell.EventKey:
-			switch ev.Key() {
-			case tcell.KeyEscape:
+			// Only keys that do not type text can leave the editor
+			if ev.Key() != tcell.KeyRune && (keyIs(ev, "back") || keyIs(ev, "quit")) {
 				return ""
+			}
+			switch ev.Key() {
 			case tcell.KeyEnter:
 				if len(lines) > 0 || len(currentLine) > 0 {
 					if len(currentLine) > 0 {
@@ -742,7 +1030,8 @@ func getMultilineInput(screen tcell.Screen, startY int, prompt string, bottomPro
 				}
 			case tcell.KeyBackspace, tcell.KeyBackspace2:
 				if len(currentLine) > 0 {
-					currentLine = currentLine[:len(currentLine)-1]
+					// Remove the whole last grapheme cluster, not just a byte
+					currentLine = dropLastCluster(currentLine)
 				}
 			default:
 				if ev.Rune() != 0 {
@@ -753,10 +1042,15 @@ func getMultilineInput(screen tcell.Screen, startY int, prompt string, bottomPro
 	}
 }
 
-func addFlashcard(filename string) error {
+func (a *app) addFlashcard(filename string) error {
+	unlock, err := lockDeck(filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Read existing file or create new one
 	var ff *FlashFile
-	var err error
 
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		// Create new file if it doesn't exist
@@ -772,13 +1066,10 @@ func addFlashcard(filename string) error {
 	}
 
 	// Initialize screen
-	screen, err := tcell.NewScreen()
+	screen, err := a.openScreen()
 	if err != nil {
 		return err
 	}
-	if err := screen.Init(); err != nil {
-		return err
-	}
 	defer screen.Fini()
 
 	// Get front of card
@@ -792,82 +1083,32 @@ func addFlashcard(filename string) error {
 	// Get back of card
 	back := getMultilineInput(screen, 2,
 		"please write card back:",
-		"press Enter to save")
+		"press Enter to preview")
 	if back == "" {
 		return nil // User cancelled
 	}
 
-	// Add the new card
-	ff.Cards = append(ff.Cards, Flashcard{
+	// Show the card as it will be reviewed before saving it
+	card := Flashcard{
 		Front: front,
 		Back:  back,
-	})
-
-	// Save the file
-	return saveFlashFile(ff)
-}
-
-func reviewWrongCards(filename string) error {
-	// Read the file
-	ff, err := parseFlashFile(filename)
-	if err != nil {
-		return fmt.Errorf("error reading file: %v", err)
-	}
-
-	// Find cards that were wrong in their last review
-	var wrongCards []int // Store indices of wrong cards
-	for i, card := range ff.Cards {
-		reviews := strings.Split(card.Reviewed, "\n")
-		if len(reviews) > 0 {
-			lastReview := reviews[len(reviews)-1]
-			if strings.HasSuffix(lastReview, "N") {
-				wrongCards = append(wrongCards, i)
-			}
-		}
-	}
-
-	if len(wrongCards) == 0 {
-		fmt.Println("No cards to review - all cards were correct in last review!")
-		return nil
-	}
-
-	// Initialize screen
-	screen, err := tcell.NewScreen()
-	if err != nil {
-		return err
+		dir:   filepath.Dir(filename),
 	}
-	if err := screen.Init(); err != nil {
-		return err
+	if !previewCard(screen, &card) {
+		return nil // User cancelled
 	}
-	defer screen.Fini()
 
-	// Track score for wrong cards review
-	reviewed := 0
-	correct := 0
-
-	// Show and review wrong cards
-	for _, idx := range wrongCards {
-		if showCard(screen, &ff.Cards[idx]) {
-			// User quit early
-			break
-		}
-		reviewed++
-		if strings.HasSuffix(ff.Cards[idx].Reviewed, "Y") {
-			correct++
-		}
-	}
+	// Add the new card
+	ff.Cards = append(ff.Cards, card)
 
-	// Save file (only card review history is updated, not the stats)
-	err = saveFlashFile(ff)
-	if err != nil {
+	// Save the file
+	if err := saveFlashFile(ff); err != nil {
 		return err
 	}
-
-	screen.Fini() // Properly close the screen
-	// Print score to terminal before exiting
-	if reviewed > 0 {
-		fmt.Printf("%d/%d\n", correct, reviewed)
-	}
+	screen.Fini()
+	unlock() // So that the hook can run flash on the deck
+	info := newCardInfo(len(ff.Cards), &ff.Cards[len(ff.Cards)-1])
+	reportHook("post-add", filename, hookInput{Card: &info})
 	return nil
 }
 
@@ -892,79 +1133,3 @@ func createNewFlashFile(name string) error {
 	// Save the empty file
 	return saveFlashFile(ff)
 }
-
-// Add this new function to handle regular review
-func handleRegularReview(selectedFile *FlashFile) {
-	// Initialize screen for flashcard review
-	screen, err := tcell.NewScreen()
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := screen.Init(); err != nil {
-		log.Fatal(err)
-	}
-	screen.Clear()
-	defer screen.Fini()
-
-	// Show title page
-	if !showTitlePage(screen, selectedFile) {
-		return // User quit
-	}
-
-	// Run through flashcards
-	correct := 0
-	total := 0
-
-	for i := range selectedFile.Cards {
-		if showCard(screen, &selectedFile.Cards[i]) {
-			// User quit early
-			break
-		}
-		total++
-		if strings.HasSuffix(selectedFile.Cards[i].Reviewed, "Y") {
-			correct++
-		}
-	}
-
-	if total > 0 {
-		// Update stats with timestamp
-		currentTime := time.Now().Format("2006/01/02 15:04")
-		newScore := fmt.Sprintf("%s    %d/%d", currentTime, correct, total)
-		if selectedFile.Stats != "" {
-			selectedFile.Stats += "\n"
-		}
-		selectedFile.Stats += newScore
-
-		// Display score comparison in UI
-		screen.Clear()
-		drawText(screen, 0, 0, "Current score:", styleTitle)
-		drawText(screen, 0, 1, newScore, styleScore)
-		drawText(screen, 0, 3, "Previous scores:", styleTitle)
-
-		// Get previous scores and count lines
-		prevScores := getPreviousScore(selectedFile)
-		scoreLines := strings.Split(prevScores, "\n")
-		numPrevScoreLines := len(scoreLines)
-
-		// Draw scores and graph side by side
-		drawText(screen, 0, 4, prevScores, styleScore)
-		drawScoreGraph(screen, 40, 4, scoreLines, 30, 10)
-
-		drawText(screen, 0, 6+numPrevScoreLines, "Press any key to exit", stylePrompt)
-		screen.Show()
-
-		// Wait for keypress and save
-		for {
-			ev := screen.PollEvent()
-			switch ev.(type) {
-			case *tcell.EventKey:
-				err = saveFlashFile(selectedFile)
-				if err != nil {
-					log.Fatal(err)
-				}
-				fmt.Printf("%d/%d\n", correct, total)
-				return
-			}
-		}
-	}
-}
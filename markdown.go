@@ -0,0 +1,428 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/uniseg"
+)
+
+// renderRaw shows card text as written instead of rendering its Markdown.
+// It is toggled with m while a card is shown and stays on for later cards.
+var renderRaw bool
+
+var (
+	reHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	reBullet   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	reNumbered = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	reQuote    = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	reTableSep = regexp.MustCompile(`^\|?(\s*:?-+:?\s*\|)*\s*:?-+:?\s*\|?$`)
+)
+
+// renderMarkdown lays out card text written in a subset of Markdown:
+// headings, bold, italic, inline code, fenced code blocks, bullet and
+// numbered lists, block quotes, tables and images on a line of their own.
+// Image paths are relative to dir, and images are scaled to fit in width
+// by height cells. Unlike Markdown, every line break in the source is kept,
+// since cards are usually written line by line.
+func renderMarkdown(text, dir string, width, height int) []styledLine {
+	var lines []styledLine
+	src := strings.Split(text, "\n")
+
+	for i := 0; i < len(src); i++ {
+		line := src[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			var code []string
+			for i++; i < len(src) && !strings.HasPrefix(strings.TrimSpace(src[i]), "```"); i++ {
+				code = append(code, src[i])
+			}
+			lines = append(lines, renderCodeBlock(code, lang, width)...)
+
+		case isTableRow(trimmed):
+			start := i
+			for i+1 < len(src) && isTableRow(strings.TrimSpace(src[i+1])) {
+				i++
+			}
+			lines = append(lines, renderTable(src[start:i+1], width)...)
+
+		case trimmed == "":
+			lines = append(lines, styledLine{})
+
+		case reImage.MatchString(trimmed):
+			m := reImage.FindStringSubmatch(trimmed)
+			lines = append(lines, renderImage(m[1], m[2], dir, width, height)...)
+
+		case reHeading.MatchString(trimmed):
+			m := reHeading.FindStringSubmatch(trimmed)
+			style := styleHeading
+			if len(m[1]) == 1 {
+				style = style.Underline(true)
+			}
+			lines = append(lines, wrapSpans(parseInline(m[2], style), width, nil, nil)...)
+
+		case reQuote.MatchString(line):
+			m := reQuote.FindStringSubmatch(line)
+			prefix := []textSpan{{"│ ", styleQuote}}
+			lines = append(lines, wrapSpans(parseInline(m[1], styleQuote), width, prefix, prefix)...)
+
+		case reBullet.MatchString(line):
+			m := reBullet.FindStringSubmatch(line)
+			indent, _ := splitIndent(m[1])
+			first := []textSpan{{indent + "• ", stylePrompt}}
+			rest := []textSpan{{indent + "  ", styleDefault}}
+			lines = append(lines, wrapSpans(parseInline(m[2], styleDefault), width, first, rest)...)
+
+		case reNumbered.MatchString(line):
+			m := reNumbered.FindStringSubmatch(line)
+			indent, _ := splitIndent(m[1])
+			first := []textSpan{{indent + m[2] + " ", stylePrompt}}
+			rest := []textSpan{{indent + strings.Repeat(" ", len(m[2])+1), styleDefault}}
+			lines = append(lines, wrapSpans(parseInline(m[3], styleDefault), width, first, rest)...)
+
+		default:
+			indent, rest := splitIndent(line)
+			prefix := []textSpan{{indent, styleDefault}}
+			lines = append(lines, wrapSpans(parseInline(rest, styleDefault), width, prefix, prefix)...)
+		}
+	}
+
+	return lines
+}
+
+// parseInline splits a line into spans for **bold**, *italic* and `code`.
+// Markers without a matching closing marker are shown as they are, and a
+// backslash escapes the next marker character.
+func parseInline(text string, base tcell.Style) []textSpan {
+	var spans []textSpan
+	var current strings.Builder
+	bold, italic := false, false
+
+	style := func() tcell.Style {
+		s := base
+		if bold {
+			s = s.Bold(true)
+		}
+		if italic {
+			s = s.Italic(true)
+		}
+		return s
+	}
+	flush := func() {
+		if current.Len() > 0 {
+			spans = append(spans, textSpan{current.String(), style()})
+			current.Reset()
+		}
+	}
+	// opens reports whether a marker at i can start an emphasis: it must be
+	// followed by text and closed later on the line
+	opens := func(i int, marker string) bool {
+		after := i + len(marker)
+		return after < len(text) && text[after] != ' ' && strings.Contains(text[after:], marker)
+	}
+
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_$", text[i+1]) >= 0:
+			current.WriteByte(text[i+1])
+			i += 2
+			continue
+
+		case text[i] == '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				flush()
+				spans = append(spans, textSpan{text[i+1 : i+1+end], styleCode})
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(text[i:], "**") || strings.HasPrefix(text[i:], "__"):
+			marker := text[i : i+2]
+			if bold || opens(i, marker) {
+				flush()
+				bold = !bold
+				i += 2
+				continue
+			}
+
+		case text[i] == '*' || text[i] == '_':
+			marker := text[i : i+1]
+			// An underscore inside a word, as in snake_case, is not a marker
+			inWord := text[i] == '_' && i > 0 && isWordByte(text[i-1])
+			if italic || (!inWord && opens(i, marker)) {
+				flush()
+				italic = !italic
+				i++
+				continue
+			}
+		}
+
+		current.WriteByte(text[i])
+		i++
+	}
+	flush()
+
+	return spans
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// spanWord is a word made of one or more differently styled pieces
+type spanWord struct {
+	spans []textSpan
+	width int
+}
+
+// splitSpanWords splits styled text into words at spaces.
+func splitSpanWords(spans []textSpan) []spanWord {
+	var words []spanWord
+	var current spanWord
+
+	for _, span := range spans {
+		start := -1
+		for i, r := range span.text {
+			if r == ' ' || r == '\t' {
+				if start >= 0 {
+					current.spans = append(current.spans, textSpan{span.text[start:i], span.style})
+					start = -1
+				}
+				if len(current.spans) > 0 {
+					words = append(words, current)
+					current = spanWord{}
+				}
+			} else if start < 0 {
+				start = i
+			}
+		}
+		if start >= 0 {
+			current.spans = append(current.spans, textSpan{span.text[start:], span.style})
+		}
+	}
+	if len(current.spans) > 0 {
+		words = append(words, current)
+	}
+
+	for i := range words {
+		for _, span := range words[i].spans {
+			words[i].width += displayWidth(span.text)
+		}
+	}
+	return words
+}
+
+// breakSpanWord breaks a word wider than width between grapheme clusters.
+func breakSpanWord(word spanWord, width int) []spanWord {
+	var pieces []spanWord
+	var current spanWord
+
+	for _, span := range word.spans {
+		var text strings.Builder
+		g := uniseg.NewGraphemes(span.text)
+		for g.Next() {
+			w := clusterWidth(g.Runes())
+			if current.width+w > width && current.width > 0 {
+				if text.Len() > 0 {
+					current.spans = append(current.spans, textSpan{text.String(), span.style})
+					text.Reset()
+				}
+				pieces = append(pieces, current)
+				current = spanWord{}
+			}
+			text.WriteString(g.Str())
+			current.width += w
+		}
+		if text.Len() > 0 {
+			current.spans = append(current.spans, textSpan{text.String(), span.style})
+		}
+	}
+	return append(pieces, current)
+}
+
+// wrapSpans lays out styled text in lines of at most width columns,
+// starting the first line with first and every other line with rest.
+func wrapSpans(spans []textSpan, width int, first, rest []textSpan) []styledLine {
+	var lines []styledLine
+
+	prefixWidth := func(prefix []textSpan) int {
+		w := 0
+		for _, span := range prefix {
+			w += displayWidth(span.text)
+		}
+		return w
+	}
+
+	prefix := first
+	current := styledLine{append([]textSpan(nil), prefix...)}
+	available := width - prefixWidth(prefix)
+	if available < 1 {
+		available = 1
+	}
+	used := 0
+
+	newLine := func() {
+		lines = append(lines, current)
+		prefix = rest
+		current = styledLine{append([]textSpan(nil), prefix...)}
+		available = width - prefixWidth(prefix)
+		if available < 1 {
+			available = 1
+		}
+		used = 0
+	}
+
+	for _, word := range splitSpanWords(spans) {
+		pieces := []spanWord{word}
+		if word.width > available {
+			if used > 0 {
+				newLine()
+			}
+			pieces = breakSpanWord(word, available)
+		}
+
+		for i, piece := range pieces {
+			if i > 0 || (used > 0 && used+1+piece.width > available) {
+				newLine()
+			}
+			if used > 0 {
+				current.spans = append(current.spans, textSpan{" ", styleDefault})
+				used++
+			}
+			current.spans = append(current.spans, piece.spans...)
+			used += piece.width
+		}
+	}
+	lines = append(lines, current)
+
+	return lines
+}
+
+// renderCodeBlock lays out the lines of a fenced code block exactly as
+// written, breaking lines that are too long instead of wrapping words.
+// Languages with a lexer are syntax highlighted.
+func renderCodeBlock(code []string, lang string, width int) []styledLine {
+	const gutter = "  "
+	var lines []styledLine
+	lx := lexers[strings.ToLower(lang)]
+	var state highlightState
+
+	for _, line := range code {
+		line = strings.ReplaceAll(line, "\t", strings.Repeat(" ", tabWidth))
+		spans := []textSpan{{line, styleCode}}
+		if lx != nil {
+			spans = highlightLine(lx, line, &state)
+		}
+		word := spanWord{spans: spans, width: displayWidth(line)}
+		for _, piece := range breakSpanWord(word, width-len(gutter)) {
+			spans := append([]textSpan{{gutter, styleDefault}}, piece.spans...)
+			lines = append(lines, styledLine{spans})
+		}
+	}
+
+	return lines
+}
+
+// isTableRow reports whether a trimmed line looks like a Markdown table row.
+func isTableRow(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "|") && strings.Count(trimmed, "|") >= 2
+}
+
+// splitTableRow returns the cells of a table row.
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	row = strings.TrimSuffix(row, "|")
+	cells := strings.Split(row, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// renderTable lays out a Markdown table with aligned columns. The first row
+// is a header if it is followed by a separator row. Tables too wide for the
+// screen fall back to one wrapped line per row.
+func renderTable(rows []string, width int) []styledLine {
+	const separator = " │ "
+
+	var cells [][][]textSpan
+	header := false
+	for i, row := range rows {
+		if reTableSep.MatchString(strings.TrimSpace(row)) {
+			header = header || i == 1
+			continue
+		}
+		var rowCells [][]textSpan
+		for _, cell := range splitTableRow(row) {
+			rowCells = append(rowCells, parseInline(cell, styleDefault))
+		}
+		cells = append(cells, rowCells)
+	}
+
+	// Measure the columns
+	var colWidths []int
+	for _, row := range cells {
+		for j, cell := range row {
+			w := 0
+			for _, span := range cell {
+				w += displayWidth(span.text)
+			}
+			if j >= len(colWidths) {
+				colWidths = append(colWidths, 0)
+			}
+			if w > colWidths[j] {
+				colWidths[j] = w
+			}
+		}
+	}
+	total := 0
+	for _, w := range colWidths {
+		total += w
+	}
+	total += displayWidth(separator) * (len(colWidths) - 1)
+
+	var lines []styledLine
+	for i, row := range cells {
+		isHeader := header && i == 0
+
+		var spans []textSpan
+		for j, cell := range row {
+			if j > 0 {
+				spans = append(spans, textSpan{separator, styleQuote})
+			}
+			w := 0
+			for _, span := range cell {
+				style := span.style
+				if isHeader {
+					style = style.Bold(true)
+				}
+				spans = append(spans, textSpan{span.text, style})
+				w += displayWidth(span.text)
+			}
+			if total <= width && j < len(row)-1 {
+				spans = append(spans, textSpan{strings.Repeat(" ", colWidths[j]-w), styleDefault})
+			}
+		}
+
+		if total > width {
+			lines = append(lines, wrapSpans(spans, width, nil, nil)...)
+			continue
+		}
+		lines = append(lines, styledLine{spans})
+
+		if isHeader {
+			var rule []string
+			for _, w := range colWidths {
+				rule = append(rule, strings.Repeat("─", w))
+			}
+			lines = append(lines, plainLine(strings.Join(rule, "─┼─"), styleQuote))
+		}
+	}
+
+	return lines
+}
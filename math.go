@@ -0,0 +1,449 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// mathSymbols maps LaTeX commands to the Unicode text they stand for
+var mathSymbols = map[string]string{
+	// Greek letters
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+
+	// Big operators
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+	"bigcup": "⋃", "bigcap": "⋂",
+
+	// Binary operators and relations
+	"pm": "±", "mp": "∓", "times": "×", "cdot": "·", "div": "÷", "ast": "∗", "star": "⋆",
+	"circ": "∘", "bullet": "∙", "oplus": "⊕", "otimes": "⊗", "cup": "∪", "cap": "∩",
+	"setminus": "∖", "land": "∧", "wedge": "∧", "lor": "∨", "vee": "∨", "neg": "¬", "lnot": "¬",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈",
+	"equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃", "subseteq": "⊆",
+	"supseteq": "⊇", "mid": "∣", "parallel": "∥", "perp": "⊥", "models": "⊨", "vdash": "⊢",
+
+	// Arrows
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "iff": "⇔", "implies": "⟹",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓",
+
+	// Other symbols
+	"infty": "∞", "partial": "∂", "nabla": "∇", "forall": "∀", "exists": "∃", "nexists": "∄",
+	"emptyset": "∅", "varnothing": "∅", "angle": "∠", "degree": "°", "prime": "′",
+	"ldots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱", "dots": "…", "hbar": "ℏ", "ell": "ℓ",
+	"aleph": "ℵ", "Re": "ℜ", "Im": "ℑ", "top": "⊤", "bot": "⊥",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"lvert": "|", "rvert": "|", "vert": "|", "Vert": "‖",
+
+	// Named functions are written upright
+	"lim": "lim", "log": "log", "ln": "ln", "exp": "exp", "sin": "sin", "cos": "cos",
+	"tan": "tan", "sec": "sec", "csc": "csc", "cot": "cot", "arcsin": "arcsin",
+	"arccos": "arccos", "arctan": "arctan", "sinh": "sinh", "cosh": "cosh", "tanh": "tanh",
+	"max": "max", "min": "min", "sup": "sup", "inf": "inf", "det": "det", "gcd": "gcd",
+	"deg": "deg", "dim": "dim", "ker": "ker", "arg": "arg", "Pr": "Pr", "mod": "mod",
+
+	// Spacing
+	",": " ", ";": " ", ":": " ", " ": " ", "quad": "  ", "qquad": "    ", "!": "",
+
+	// Escaped characters
+	"{": "{", "}": "}", "%": "%", "$": "$", "&": "&", "#": "#", "_": "_", "|": "‖",
+}
+
+// mathAccents maps accent commands to combining characters
+var mathAccents = map[string]string{
+	"hat": "̂", "widehat": "̂", "bar": "̄", "overline": "̅",
+	"tilde": "̃", "widetilde": "̃", "vec": "⃗", "dot": "̇",
+	"ddot": "̈", "acute": "́", "grave": "̀", "check": "̌",
+}
+
+var blackboard = map[rune]string{
+	'N': "ℕ", 'Z': "ℤ", 'Q': "ℚ", 'R': "ℝ", 'C': "ℂ", 'P': "ℙ", 'H': "ℍ", 'E': "𝔼", '1': "𝟙",
+}
+
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+	'+': '⁺', '-': '⁻', '−': '⁻', '=': '⁼', '(': '⁽', ')': '⁾', ' ': ' ', '′': '′', '*': '*', '∗': '*',
+	'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ', 'f': 'ᶠ', 'g': 'ᵍ', 'h': 'ʰ', 'i': 'ⁱ',
+	'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ', 'm': 'ᵐ', 'n': 'ⁿ', 'o': 'ᵒ', 'p': 'ᵖ', 'r': 'ʳ', 's': 'ˢ',
+	't': 'ᵗ', 'u': 'ᵘ', 'v': 'ᵛ', 'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ', 'z': 'ᶻ',
+	'A': 'ᴬ', 'B': 'ᴮ', 'D': 'ᴰ', 'E': 'ᴱ', 'G': 'ᴳ', 'H': 'ᴴ', 'I': 'ᴵ', 'J': 'ᴶ', 'K': 'ᴷ',
+	'L': 'ᴸ', 'M': 'ᴹ', 'N': 'ᴺ', 'O': 'ᴼ', 'P': 'ᴾ', 'R': 'ᴿ', 'T': 'ᵀ', 'U': 'ᵁ', 'V': 'ⱽ',
+	'W': 'ᵂ', 'β': 'ᵝ', 'γ': 'ᵞ', 'δ': 'ᵟ', 'θ': 'ᶿ', 'ϕ': 'ᵠ', 'φ': 'ᵠ', 'χ': 'ᵡ',
+}
+
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+	'+': '₊', '-': '₋', '−': '₋', '=': '₌', '(': '₍', ')': '₎', ' ': ' ',
+	'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ', 'n': 'ₙ',
+	'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ', 's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ', 'v': 'ᵥ', 'x': 'ₓ',
+	'β': 'ᵦ', 'γ': 'ᵧ', 'ρ': 'ᵨ', 'ϕ': 'ᵩ', 'φ': 'ᵩ', 'χ': 'ᵪ',
+}
+
+var vulgarFractions = map[string]string{
+	"1/2": "½", "1/3": "⅓", "2/3": "⅔", "1/4": "¼", "3/4": "¾", "1/5": "⅕", "2/5": "⅖",
+	"3/5": "⅗", "4/5": "⅘", "1/6": "⅙", "5/6": "⅚", "1/7": "⅐", "1/8": "⅛", "3/8": "⅜",
+	"5/8": "⅝", "7/8": "⅞", "1/9": "⅑", "1/10": "⅒",
+}
+
+// mapScript converts text to superscript or subscript characters, failing
+// if any character has no such form.
+func mapScript(text string, table map[rune]rune) (string, error) {
+	var out strings.Builder
+	for _, r := range text {
+		mapped, ok := table[r]
+		if !ok {
+			return "", fmt.Errorf("no script form for %q", r)
+		}
+		out.WriteRune(mapped)
+	}
+	return out.String(), nil
+}
+
+// mathParser converts the LaTeX inside one $...$ span
+type mathParser struct {
+	src []rune
+	pos int
+}
+
+func (p *mathParser) peek() rune {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// parseSequence converts atoms until the end of the input or, inside a
+// group, the closing brace.
+func (p *mathParser) parseSequence(inGroup bool) (string, error) {
+	var out strings.Builder
+	for p.pos < len(p.src) {
+		c := p.peek()
+		switch c {
+		case '}':
+			if inGroup {
+				return out.String(), nil
+			}
+			return "", fmt.Errorf("unbalanced }")
+		case '^', '_':
+			p.pos++
+			atom, err := p.parseAtom()
+			if err != nil {
+				return "", err
+			}
+			table := superscripts
+			if c == '_' {
+				table = subscripts
+			}
+			script, err := mapScript(atom, table)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(script)
+		case '&', '~':
+			p.pos++
+			out.WriteRune(' ')
+		default:
+			atom, err := p.parseAtom()
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(atom)
+		}
+	}
+	if inGroup {
+		return "", fmt.Errorf("missing }")
+	}
+	return out.String(), nil
+}
+
+// parseArgument reads the argument of a command: a {...} group or a
+// single atom.
+func (p *mathParser) parseArgument() (string, error) {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		return "", fmt.Errorf("missing argument")
+	}
+	return p.parseAtom()
+}
+
+// rawGroup returns the text of a {...} group without converting it.
+func (p *mathParser) rawGroup() (string, error) {
+	if p.peek() != '{' {
+		return "", fmt.Errorf("expected {")
+	}
+	depth := 0
+	start := p.pos + 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return string(p.src[start : p.pos-1]), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("missing }")
+}
+
+func (p *mathParser) parseAtom() (string, error) {
+	c := p.peek()
+	switch {
+	case c == '{':
+		p.pos++
+		inner, err := p.parseSequence(true)
+		if err != nil {
+			return "", err
+		}
+		p.pos++ // Closing brace
+		return inner, nil
+	case c == '\\':
+		return p.parseCommand()
+	case c == '-':
+		p.pos++
+		return "−", nil
+	case c == '\'':
+		p.pos++
+		return "′", nil
+	default:
+		p.pos++
+		return string(c), nil
+	}
+}
+
+func (p *mathParser) parseCommand() (string, error) {
+	p.pos++ // Backslash
+	start := p.pos
+	for p.pos < len(p.src) && unicode.IsLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start && p.pos < len(p.src) {
+		p.pos++ // Single character command such as \, or \{
+	}
+	name := string(p.src[start:p.pos])
+
+	if symbol, ok := mathSymbols[name]; ok {
+		return symbol, nil
+	}
+	if accent, ok := mathAccents[name]; ok {
+		arg, err := p.parseArgument()
+		if err != nil {
+			return "", err
+		}
+		var out strings.Builder
+		for _, r := range arg {
+			out.WriteRune(r)
+			out.WriteString(accent)
+		}
+		return out.String(), nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		num, err := p.parseArgument()
+		if err != nil {
+			return "", err
+		}
+		den, err := p.parseArgument()
+		if err != nil {
+			return "", err
+		}
+		return formatFraction(num, den), nil
+
+	case "sqrt":
+		index := ""
+		if p.peek() == '[' {
+			end := p.pos
+			for end < len(p.src) && p.src[end] != ']' {
+				end++
+			}
+			if end == len(p.src) {
+				return "", fmt.Errorf("missing ]")
+			}
+			index = string(p.src[p.pos+1 : end])
+			p.pos = end + 1
+		}
+		arg, err := p.parseArgument()
+		if err != nil {
+			return "", err
+		}
+		root := "√"
+		switch index {
+		case "":
+		case "3":
+			root = "∛"
+		case "4":
+			root = "∜"
+		default:
+			sup, err := mapScript(index, superscripts)
+			if err != nil {
+				return "", err
+			}
+			root = sup + "√"
+		}
+		if isSimpleMath(arg) {
+			return root + arg, nil
+		}
+		return root + "(" + arg + ")", nil
+
+	case "text", "textrm", "mathrm", "mathit", "mathbf", "textbf", "operatorname", "mbox":
+		return p.rawGroup()
+
+	case "mathbb":
+		arg, err := p.rawGroup()
+		if err != nil {
+			return "", err
+		}
+		var out strings.Builder
+		for _, r := range arg {
+			if bb, ok := blackboard[r]; ok {
+				out.WriteString(bb)
+			} else {
+				return "", fmt.Errorf("no blackboard form for %q", r)
+			}
+		}
+		return out.String(), nil
+
+	case "left", "right", "big", "Big", "bigl", "bigr", "Bigl", "Bigr", "displaystyle":
+		// Sizing only, the delimiter that follows is drawn as is
+		if p.peek() == '.' {
+			p.pos++
+		}
+		return "", nil
+	}
+
+	return "", fmt.Errorf("unsupported command \\%s", name)
+}
+
+// isSimpleMath reports whether converted math is a single term that needs
+// no parentheses.
+func isSimpleMath(text string) bool {
+	return text != "" && !strings.ContainsAny(text, " +−-*/=·×,<>≤≥")
+}
+
+// formatFraction writes a fraction using a vulgar fraction character when
+// there is one, small digits for other numbers, and a slash otherwise.
+func formatFraction(num, den string) string {
+	if vulgar, ok := vulgarFractions[num+"/"+den]; ok {
+		return vulgar
+	}
+	if sup, err := mapScript(num, superscripts); err == nil && strings.Trim(num, "0123456789") == "" {
+		if sub, err := mapScript(den, subscripts); err == nil && strings.Trim(den, "0123456789") == "" {
+			return sup + "⁄" + sub
+		}
+	}
+	if !isSimpleMath(num) {
+		num = "(" + num + ")"
+	}
+	if !isSimpleMath(den) {
+		den = "(" + den + ")"
+	}
+	return num + "/" + den
+}
+
+// latexToUnicode converts the source of a math span to plain Unicode text.
+func latexToUnicode(src string) (string, error) {
+	p := &mathParser{src: []rune(src)}
+	return p.parseSequence(false)
+}
+
+// renderMath replaces $...$ spans in card text with readable Unicode. A
+// span opens with a $ not followed by a space and closes with a $ not
+// preceded by a space nor followed by a digit, so prices and shell
+// variables are left alone. Code is skipped, and spans that cannot be
+// converted are kept as written.
+func renderMath(text string) string {
+	lines := strings.Split(text, "\n")
+	inFence := false
+
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || !strings.Contains(line, "$") {
+			continue
+		}
+		lines[i] = renderMathLine(line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderMathLine(line string) string {
+	var out strings.Builder
+
+	for i := 0; i < len(line); {
+		c := line[i]
+
+		switch {
+		case c == '\\' && i+1 < len(line):
+			// Escaped character, kept for the Markdown renderer
+			out.WriteString(line[i : i+2])
+			i += 2
+			continue
+
+		case c == '`':
+			// Inline code is left alone
+			if end := strings.IndexByte(line[i+1:], '`'); end >= 0 {
+				out.WriteString(line[i : i+end+2])
+				i += end + 2
+				continue
+			}
+
+		case c == '$':
+			delim := "$"
+			if strings.HasPrefix(line[i:], "$$") {
+				delim = "$$"
+			}
+			start := i + len(delim)
+			if end := findMathEnd(line, start, delim); end > start {
+				if converted, err := latexToUnicode(line[start:end]); err == nil {
+					out.WriteString(converted)
+					i = end + len(delim)
+					continue
+				}
+			}
+		}
+
+		out.WriteByte(c)
+		i++
+	}
+
+	return out.String()
+}
+
+// findMathEnd returns the position of the delimiter closing a math span
+// that starts at start, or -1.
+func findMathEnd(line string, start int, delim string) int {
+	if start >= len(line) || line[start] == ' ' {
+		return -1
+	}
+	for j := start; j < len(line); j++ {
+		if line[j] == '\\' {
+			j++
+			continue
+		}
+		if !strings.HasPrefix(line[j:], delim) {
+			continue
+		}
+		after := j + len(delim)
+		if line[j-1] == ' ' || (after < len(line) && line[after] >= '0' && line[after] <= '9') {
+			return -1
+		}
+		return j
+	}
+	return -1
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// navResult is how the user left a screen
+type navResult int
+
+const (
+	navNext navResult = iota // Go on to the next screen
+	navBack                  // Step back to the previous screen
+	navQuit                  // Leave flash
+)
+
+// errBack is returned by runSession when the user steps back from the
+// title page of a deck chosen in the deck picker.
+var errBack = errors.New("stepped back to the deck picker")
+
+// keyHelp describes each action in the help overlay
+var keyHelp = map[string]string{
+	"quit":      "quit",
+	"back":      "go back a screen",
+	"help":      "show this help",
+	"command":   "enter a command (:tag T, :goto N, :quit)",
+	"confirm":   "continue, or select the highlighted item",
+	"reveal":    "show the back of the card",
+	"correct":   "grade the card correct",
+	"wrong":     "grade the card wrong",
+	"suspend":   "suspend the card",
+	"bury":      "bury the card until tomorrow",
+	"flag":      "flag or unflag the card",
+	"raw":       "switch between raw and rendered text",
+	"up":        "move or scroll up",
+	"down":      "move or scroll down",
+	"page-up":   "page up",
+	"page-down": "page down",
+	"first":     "go to the first item",
+	"last":      "go to the last item",
+	"next":      "next card",
+	"previous":  "previous card",
+	"resume":    "resume the unfinished session",
+	"restart":   "start a new session",
+}
+
+// The actions each screen responds to, in the order the help lists them
+var (
+	pickerActions  = []string{"up", "down", "first", "last", "confirm", "command", "help", "quit"}
+	reviewActions  = []string{"reveal", "correct", "wrong", "suspend", "bury", "flag", "raw", "up", "down", "page-up", "page-down", "command", "help", "back", "quit"}
+	browseActions  = []string{"next", "previous", "first", "last", "up", "down", "page-up", "page-down", "raw", "command", "help", "back", "quit"}
+	previewActions = []string{"confirm", "raw", "up", "down", "page-up", "page-down", "help", "back"}
+)
+
+// showHelp draws the keys for the given actions in a box over the screen
+// and waits for a key. The caller redraws the screen afterwards.
+func showHelp(screen tcell.Screen, actions []string) {
+	var keys []string
+	keysWidth := 0
+	for _, action := range actions {
+		names := strings.Join(cfg.Keys[action], ", ")
+		if action == "quit" {
+			names += ", ctrl-c"
+		}
+		keys = append(keys, names)
+		keysWidth = max(keysWidth, displayWidth(names))
+	}
+	var lines []string
+	for i, action := range actions {
+		lines = append(lines, fmt.Sprintf("%-*s  %s", keysWidth, keys[i], keyHelp[action]))
+	}
+	lines = append(lines, "", "Press any key to close")
+
+	for {
+		width, height := screen.Size()
+		boxWidth := len("Keys") + 4
+		for _, line := range lines {
+			if w := displayWidth(line) + 4; w > boxWidth {
+				boxWidth = w
+			}
+		}
+		boxWidth = min(boxWidth, width)
+		boxHeight := min(len(lines)+4, height)
+		x0 := (width - boxWidth) / 2
+		y0 := (height - boxHeight) / 2
+
+		// Frame and blank interior
+		top, bottom := y0, y0+boxHeight-1
+		left, right := x0, x0+boxWidth-1
+		for y := top; y <= bottom; y++ {
+			for x := left; x <= right; x++ {
+				r := ' '
+				switch {
+				case y == top && x == left:
+					r = '┌'
+				case y == top && x == right:
+					r = '┐'
+				case y == bottom && x == left:
+					r = '└'
+				case y == bottom && x == right:
+					r = '┘'
+				case y == top || y == bottom:
+					r = '─'
+				case x == left || x == right:
+					r = '│'
+				}
+				screen.SetContent(x, y, r, nil, stylePrompt)
+			}
+		}
+		drawString(screen, x0+2, y0, " Keys ", styleTitle)
+		for i, line := range lines {
+			if y0+2+i >= bottom {
+				break
+			}
+			drawString(screen, x0+2, y0+2+i, line, styleDefault)
+		}
+		screen.Show()
+
+		switch screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			return
+		}
+	}
+}
+
+// readCommand shows a : prompt on the bottom line of the screen and
+// returns the command typed, or false if it was cancelled.
+func readCommand(screen tcell.Screen) (string, bool) {
+	defer screen.HideCursor()
+	text := ""
+
+	for {
+		width, height := screen.Size()
+		for x := 0; x < width; x++ {
+			screen.SetContent(x, height-1, ' ', nil, styleDefault)
+		}
+		x := drawString(screen, 0, height-1, ":"+text, stylePrompt)
+		screen.ShowCursor(x, height-1)
+		screen.Show()
+
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyEnter:
+				return strings.TrimSpace(text), true
+			case tcell.KeyEscape, tcell.KeyCtrlC:
+				return "", false
+			case tcell.KeyBackspace, tcell.KeyBackspace2:
+				if text == "" {
+					return "", false
+				}
+				text = dropLastCluster(text)
+			case tcell.KeyRune:
+				text += string(ev.Rune())
+			}
+		}
+	}
+}
+
+// screenCommand is a command entered at the : prompt
+type screenCommand struct {
+	name string // "tag", "goto" or "quit"
+	tag  string
+	line int // Card or item number for goto, counting from 1
+}
+
+// parseCommand reads a : command. A bare number is short for goto.
+func parseCommand(text string) (screenCommand, error) {
+	name, arg, _ := strings.Cut(text, " ")
+	arg = strings.TrimSpace(arg)
+	if n, err := strconv.Atoi(name); err == nil && arg == "" {
+		name, arg = "goto", strconv.Itoa(n)
+	}
+
+	switch name {
+	case "q", "quit":
+		return screenCommand{name: "quit"}, nil
+	case "tag":
+		if arg == "" || strings.ContainsAny(arg, " \t") {
+			return screenCommand{}, fmt.Errorf("usage: :tag NAME")
+		}
+		return screenCommand{name: "tag", tag: arg}, nil
+	case "goto":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return screenCommand{}, fmt.Errorf("usage: :goto NUMBER")
+		}
+		return screenCommand{name: "goto", line: n}, nil
+	case "":
+		return screenCommand{}, fmt.Errorf("no command")
+	}
+	return screenCommand{}, fmt.Errorf("unknown command: %s", name)
+}
+
+// promptCommand reads a : command. It returns a command with no name if
+// the prompt was cancelled, along with a message saying what was wrong if
+// the command was not understood.
+func promptCommand(screen tcell.Screen) (screenCommand, string) {
+	text, ok := readCommand(screen)
+	if !ok {
+		return screenCommand{}, ""
+	}
+	cmd, err := parseCommand(text)
+	if err != nil {
+		return screenCommand{}, err.Error()
+	}
+	return cmd, ""
+}
+
+// moveCursor applies a list movement key to cursor in a list of n items
+// showing page items at a time. It reports false if ev is not a movement
+// key.
+func moveCursor(ev *tcell.EventKey, cursor, n, page int) (int, bool) {
+	switch {
+	case keyIs(ev, "up"):
+		cursor--
+	case keyIs(ev, "down"):
+		cursor++
+	case keyIs(ev, "page-up"):
+		cursor -= max(page, 1)
+	case keyIs(ev, "page-down"):
+		cursor += max(page, 1)
+	case keyIs(ev, "first"):
+		cursor = 0
+	case keyIs(ev, "last"):
+		cursor = n - 1
+	default:
+		return cursor, false
+	}
+	return max(0, min(cursor, n-1)), true
+}
+
+// mouseButtons remembers which buttons were down at the last mouse event,
+// so that a click is reported once when pressed and not again while the
+// button is held or dragged. It is shared by every screen since the
+// button stays down while one screen replaces another.
+var mouseButtons tcell.ButtonMask
+
+// mouseClick reports where the primary button was just pressed.
+func mouseClick(ev *tcell.EventMouse) (int, int, bool) {
+	pressed := ev.Buttons()&tcell.Button1 != 0 && mouseButtons&tcell.Button1 == 0
+	mouseButtons = ev.Buttons()
+	x, y := ev.Position()
+	return x, y, pressed
+}
+
+// wheelScroll returns how many lines a mouse wheel event scrolls, or 0.
+func wheelScroll(ev *tcell.EventMouse) int {
+	switch {
+	case ev.Buttons()&tcell.WheelUp != 0:
+		return -3
+	case ev.Buttons()&tcell.WheelDown != 0:
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// plainUI runs a session with plain lines of text on stdin and stdout, for
+// dumb terminals, screen readers and scripts. The front is printed, a line
+// is read to reveal the back, and then a y/n grade is read. An answer typed
+// on the reveal line that matches the back is graded correct right away.
+type plainUI struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPlainUI(in io.Reader, out io.Writer) *plainUI {
+	return &plainUI{in: bufio.NewReader(in), out: out}
+}
+
+// readLine reads a line without its line ending. It returns false at the
+// end of the input, ending the prompt's line.
+func (u *plainUI) readLine() (string, bool) {
+	line, err := u.in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(u.out)
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+// normalizeAnswer makes typed answers comparable by ignoring case and
+// spacing.
+func normalizeAnswer(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+func (u *plainUI) TitlePage(ff *FlashFile) navResult {
+	fmt.Fprintln(u.out, strings.TrimSpace(ff.Title))
+	return navNext
+}
+
+func (u *plainUI) Resume(saved *sessionState) (bool, navResult) {
+	fmt.Fprintln(u.out, "\nAn unfinished session was found")
+	fmt.Fprintln(u.out, resumeDetails(saved))
+	for {
+		fmt.Fprint(u.out, "Resume it? [y/n, q to quit] ")
+		line, ok := u.readLine()
+		if !ok {
+			return false, navQuit
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes", "":
+			return true, navNext
+		case "n", "no":
+			return false, navNext
+		case "q", "quit":
+			return false, navQuit
+		}
+	}
+}
+
+func (u *plainUI) Card(card *Flashcard, countdown time.Duration) (cardResult, time.Duration, bool) {
+	fmt.Fprintf(u.out, "\nFront:\n%s\n\n", strings.TrimSpace(card.Front))
+	if countdown > 0 {
+		fmt.Fprintf(u.out, "You have %.0f seconds. ", countdown.Seconds())
+	}
+	fmt.Fprint(u.out, "Press Enter to see the back, or type your answer: ")
+
+	start := time.Now()
+	answer, ok := u.readLine()
+	if !ok {
+		return cardQuit, 0, false
+	}
+	latency := time.Since(start)
+	fmt.Fprintf(u.out, "\nBack:\n%s\n\n", strings.TrimSpace(card.Back))
+
+	if countdown > 0 && latency >= countdown {
+		fmt.Fprintln(u.out, "✗ Out of time")
+		return cardWrong, countdown, false
+	}
+	if answer = normalizeAnswer(answer); answer != "" {
+		if answer == normalizeAnswer(card.Back) {
+			fmt.Fprintln(u.out, "✓ Correct")
+			return cardCorrect, latency, false
+		}
+		fmt.Fprintln(u.out, "Your answer does not match.")
+	}
+
+	changed := false
+	for {
+		fmt.Fprint(u.out, "Did you get it right? [y/n, s to suspend, b to bury, f to flag, q to quit] ")
+		line, ok := u.readLine()
+		if !ok {
+			return cardQuit, latency, changed
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			fmt.Fprintln(u.out, "✓ Correct")
+			return cardCorrect, latency, changed
+		case "n", "no":
+			fmt.Fprintln(u.out, "✗ Wrong")
+			return cardWrong, latency, changed
+		case "s":
+			card.Suspended = true
+			fmt.Fprintln(u.out, "Suspended")
+			return cardSkipped, latency, changed
+		case "b":
+			card.bury(time.Now())
+			fmt.Fprintln(u.out, "Buried until tomorrow")
+			return cardSkipped, latency, changed
+		case "f":
+			card.Flagged = !card.Flagged
+			changed = true
+			if card.Flagged {
+				fmt.Fprintln(u.out, "Flagged")
+			} else {
+				fmt.Fprintln(u.out, "Unflagged")
+			}
+		case "q", "quit":
+			fmt.Fprintln(u.out)
+			return cardQuit, latency, changed
+		}
+	}
+}
+
+func (u *plainUI) Leech(card *Flashcard) {
+	fmt.Fprintf(u.out, "Leech detected: %s\n", leechMessage(card))
+}
+
+// Summary leaves the summary to the score runSession prints afterwards.
+func (u *plainUI) Summary(sessionMode, *FlashFile, *sessionState, reviewResult, string) bool {
+	return false
+}
+
+func (u *plainUI) Close() {}
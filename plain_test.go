@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const plainDeck = `###
+Spanish
+###
+&&&
+&&&
+***
+!FRONT
+lunes
+!BACK
+Monday
+***
+***
+!FRONT
+martes
+!BACK
+Tuesday
+***
+***
+!FRONT
+miércoles
+!BACK
+Wednesday
+***
+***
+!FRONT
+jueves
+!BACK
+Thursday
+***
+`
+
+func TestPlainReview(t *testing.T) {
+	path := writeDeck(t, plainDeck)
+	var out bytes.Buffer
+	input := strings.Join([]string{
+		"", "y", // Reveal, then grade correct
+		"  TUESDAY ", // A typed answer matching the back
+		"", "n",      // Reveal, then grade wrong
+		// The input ends on the last card, which quits
+	}, "\n") + "\n"
+	a := &app{in: strings.NewReader(input), out: &out}
+
+	opts := defaultReviewOptions()
+	opts.Plain = true
+	if err := a.studyDeck(path, dueMode(), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	printed := out.String()
+	for _, want := range []string{"Spanish\n", "Front:\nlunes\n", "Back:\nMonday\n", "✓ Correct", "✗ Wrong", "Front:\njueves\n"} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("output does not contain %q:\n%s", want, printed)
+		}
+	}
+	if !strings.HasSuffix(printed, "2/3\n") {
+		t.Errorf("output does not end with the score 2/3:\n%s", printed)
+	}
+
+	ff, err := parseFlashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006/01/02")
+	for i, want := range []string{today + " Y", today + " Y", today + " N", ""} {
+		if got := ff.Cards[i].Reviewed; got != want {
+			t.Errorf("card %d reviewed %q, want %q", i+1, got, want)
+		}
+	}
+	if !strings.HasSuffix(ff.Stats, "2/3") {
+		t.Errorf("stats %q do not end with the score", ff.Stats)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// sessionState is the progress of a review session, saved next to the deck
+// when the session is interrupted so that it can be resumed later
+type sessionState struct {
+	Mode       string        `json:"mode"`        // "all", "wrong", "cram" or "drill"
+	Cards      []int         `json:"cards"`       // Card indices in presentation order
+	Index      int           `json:"index"`       // Position of the next card to show
+	Correct    int           `json:"correct"`     // Running score
+	Total      int           `json:"total"`       // Cards graded so far
+	Seed       int64         `json:"seed"`        // Shuffle seed, 0 if not shuffled
+	CardCount  int           `json:"card_count"`  // Cards in the deck, to detect edits
+	StatsEntry string        `json:"stats_entry"` // Stats line written for the partial score
+	HeldBack   int           `json:"held_back"`   // New cards left out by the daily limit
+	Started    time.Time     `json:"started"`
+	Options    reviewOptions `json:"options"`
+}
+
+// resumableModes are the modes whose sessions can be saved and resumed
+var resumableModes = []string{"all", "wrong"}
+
+// sessionPath returns the hidden file holding the unfinished session of a
+// deck in the given mode. Each mode has its own, so that finishing one kind
+// of session does not throw away another.
+func sessionPath(filename, mode string) string {
+	name := "." + filepath.Base(filename)
+	if mode != "all" {
+		name += "." + mode
+	}
+	return filepath.Join(filepath.Dir(filename), name+".session")
+}
+
+// loadSessionState returns the unfinished session of a deck in the given
+// mode, or nil if there is none or it no longer matches the deck.
+func loadSessionState(ff *FlashFile, mode string) (*sessionState, error) {
+	data, err := os.ReadFile(sessionPath(ff.Filename, mode))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state sessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error reading session: %v", err)
+	}
+	if state.Mode != mode {
+		return nil, nil
+	}
+
+	// Cards were added or removed since, the saved order is meaningless
+	if state.CardCount != len(ff.Cards) || state.Index >= len(state.Cards) {
+		return nil, nil
+	}
+	for _, idx := range state.Cards {
+		if idx < 0 || idx >= len(ff.Cards) {
+			return nil, nil
+		}
+	}
+	return &state, nil
+}
+
+func saveSessionState(ff *FlashFile, state *sessionState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sessionPath(ff.Filename, state.Mode), data, 0644)
+}
+
+func clearSessionState(ff *FlashFile, mode string) error {
+	err := os.Remove(sessionPath(ff.Filename, mode))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// newSessionState starts a session over the given cards, applying the
+// deck's daily new-card limit to regular reviews and drills, which both
+// record reviews, and shuffling them if the options ask for it.
+func newSessionState(ff *FlashFile, mode string, indices []int, opts reviewOptions) *sessionState {
+	cards, held := append([]int(nil), indices...), 0
+	if mode == "all" || mode == "drill" {
+		cards, held = limitNewCards(ff, cards)
+	}
+	if opts.Shuffle {
+		if opts.Seed == 0 {
+			opts.Seed = time.Now().UnixNano()
+		}
+		rng := rand.New(rand.NewSource(opts.Seed))
+		rng.Shuffle(len(cards), func(i, j int) {
+			cards[i], cards[j] = cards[j], cards[i]
+		})
+	}
+
+	return &sessionState{
+		Mode:      mode,
+		Cards:     cards,
+		Seed:      opts.Seed,
+		CardCount: len(ff.Cards),
+		HeldBack:  held,
+		Started:   time.Now(),
+		Options:   opts,
+	}
+}
+
+// startSession returns the session to run on a deck. If an unfinished
+// session of the same mode exists, the user is asked whether to resume it,
+// unless opts.Resume is set. It returns how the user left the prompt.
+func startSession(ui sessionUI, ff *FlashFile, mode string, indices []int, opts reviewOptions) (*sessionState, navResult) {
+	saved, err := loadSessionState(ff, mode)
+	if err != nil {
+		log.Printf("Ignoring unfinished session: %v\n", err)
+	}
+	if saved == nil {
+		return newSessionState(ff, mode, indices, opts), navNext
+	}
+	if opts.Resume {
+		return saved, navNext
+	}
+
+	resume, nav := ui.Resume(saved)
+	switch {
+	case nav != navNext:
+		return nil, nav
+	case resume:
+		return saved, navNext
+	}
+	return newSessionState(ff, mode, indices, opts), navNext
+}
+
+// resumeDetails describes an unfinished session for the resume prompt.
+func resumeDetails(saved *sessionState) string {
+	return fmt.Sprintf("Card %d of %d, %d/%d correct so far (started %s)",
+		saved.Index+1, len(saved.Cards), saved.Correct, saved.Total, saved.Started.Format("2006/01/02 15:04"))
+}
+
+// promptResume asks on the screen whether to resume an unfinished session.
+// The answer only counts if it returns navNext.
+func promptResume(screen tcell.Screen, saved *sessionState) (bool, navResult) {
+	for {
+		screen.Clear()
+		drawText(screen, 0, 0, "An unfinished session was found", styleTitle)
+		drawText(screen, 0, 2, resumeDetails(saved), styleScore)
+		drawText(screen, 0, 4, fmt.Sprintf("Press %s to resume, %s to start a new session, %s to quit",
+			keyLabel("resume"), keyLabel("restart"), keyLabel("quit")), stylePrompt)
+		screen.Show()
+
+		ev := screen.PollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			switch {
+			case keyIs(ev, "quit"):
+				return false, navQuit
+			case keyIs(ev, "back"):
+				return false, navBack
+			case keyIs(ev, "resume"):
+				return true, navNext
+			case keyIs(ev, "restart"):
+				return false, navNext
+			}
+		}
+	}
+}
+
+// persistSession saves the session next to the deck if it was interrupted,
+// or removes a previously saved one if it ran to the end.
+func persistSession(ff *FlashFile, state *sessionState) error {
+	if state.Index < len(state.Cards) && state.Total > 0 {
+		return saveSessionState(ff, state)
+	}
+	if state.Index < len(state.Cards) {
+		// Nothing graded yet, keep whatever was saved before
+		return nil
+	}
+	return clearSessionState(ff, state.Mode)
+}
+
+// recordSessionScore writes the session score to the deck's stats. A
+// resumed session replaces the partial score it wrote earlier instead of
+// adding a second entry.
+func recordSessionScore(ff *FlashFile, state *sessionState) string {
+	currentTime := time.Now().Format("2006/01/02 15:04")
+	newScore := fmt.Sprintf("%s    %d/%d", currentTime, state.Correct, state.Total)
+
+	replaced := false
+	if state.StatsEntry != "" {
+		lines := strings.Split(ff.Stats, "\n")
+		for i, line := range lines {
+			if line == state.StatsEntry {
+				lines[i] = newScore
+				replaced = true
+				break
+			}
+		}
+		ff.Stats = strings.Join(lines, "\n")
+	}
+	if !replaced {
+		if ff.Stats != "" {
+			ff.Stats += "\n"
+		}
+		ff.Stats += newScore
+	}
+
+	state.StatsEntry = newScore
+	return newScore
+}
+
+// resumeSession continues the unfinished session of a deck, in plain lines
+// if plain is set. With sessions of several modes saved, the one started
+// last is resumed.
+func (a *app) resumeSession(filename string, plain bool) error {
+	ff, err := parseFlashFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	var state *sessionState
+	for _, mode := range resumableModes {
+		saved, err := loadSessionState(ff, mode)
+		if err != nil {
+			return err
+		}
+		if saved != nil && (state == nil || saved.Started.After(state.Started)) {
+			state = saved
+		}
+	}
+	if state == nil {
+		return fmt.Errorf("no unfinished session for %s", filename)
+	}
+
+	opts := state.Options
+	opts.Resume = true
+	opts.Plain = plain
+	mode := dueMode()
+	if state.Mode == "wrong" {
+		mode = wrongMode()
+	}
+	return a.runSession(ff, mode, opts)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Cards 2 and 3 were answered wrong, so flash review shows them
+const resumeDeck = `###
+Spanish
+###
+&&&
+&&&
+***
+!FRONT
+lunes
+!BACK
+Monday
+***
+***
+!FRONT
+martes
+!BACK
+Tuesday
+!REVIEWED
+2024/01/02 N
+***
+***
+!FRONT
+miércoles
+!BACK
+Wednesday
+!REVIEWED
+2024/01/02 N
+***
+`
+
+func TestSessionsOfEachMode(t *testing.T) {
+	path := writeDeck(t, resumeDeck)
+	study := func(mode sessionMode, resume bool, lines ...string) {
+		t.Helper()
+		a := &app{in: strings.NewReader(strings.Join(lines, "\n") + "\n"), out: &bytes.Buffer{}}
+		opts := defaultReviewOptions()
+		opts.Plain = true
+		opts.Resume = resume
+		if err := a.runSession(mustParse(t, path), mode, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := func(mode string) *sessionState {
+		t.Helper()
+		state, err := loadSessionState(mustParse(t, path), mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state
+	}
+
+	// Interrupt a review, then a review of the wrong cards
+	study(dueMode(), false, "", "y", "q")
+	study(wrongMode(), false, "", "y", "q")
+	if state := saved("all"); state == nil || state.Index != 1 {
+		t.Fatalf("saved review %+v, want one stopped at the second card", state)
+	}
+	if state := saved("wrong"); state == nil || state.Index != 1 || len(state.Cards) != 2 {
+		t.Fatalf("saved wrong review %+v, want one stopped at its second card", state)
+	}
+
+	// Finishing the review of the wrong cards leaves the other one
+	study(wrongMode(), true, "", "y")
+	if state := saved("wrong"); state != nil {
+		t.Errorf("finished wrong review is still saved: %+v", state)
+	}
+	if state := saved("all"); state == nil || state.Index != 1 {
+		t.Errorf("saved review %+v, want it kept", state)
+	}
+}
+
+func mustParse(t *testing.T, path string) *FlashFile {
+	t.Helper()
+	ff, err := parseFlashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ff
+}
+
+func TestNewCardLimit(t *testing.T) {
+	deck := strings.Replace(plainDeck, "&&&\n&&&\n", "&&&\n&&&\n@@@\nnew-per-day: 2\n@@@\n", 1)
+	ff := mustParse(t, writeDeck(t, deck))
+	indices := availableCards(ff)
+
+	for _, mode := range []string{"all", "drill"} {
+		state := newSessionState(ff, mode, indices, defaultReviewOptions())
+		if len(state.Cards) != 2 || state.HeldBack != 2 {
+			t.Errorf("%s session has cards %v and held back %d, want 2 and 2", mode, state.Cards, state.HeldBack)
+		}
+	}
+	if state := newSessionState(ff, "cram", indices, defaultReviewOptions()); len(state.Cards) != 4 {
+		t.Errorf("cram session has cards %v, want all 4", state.Cards)
+	}
+}
+
+func TestRemainingSkipsHiddenCards(t *testing.T) {
+	ff := mustParse(t, writeDeck(t, plainDeck))
+	opts := defaultReviewOptions()
+	opts.Limit = 1
+	state := newSessionState(ff, "all", availableCards(ff), opts)
+
+	// Hidden since the session started, as when resuming it
+	ff.Cards[2].Suspended = true
+	ff.Cards[3].bury(time.Now())
+
+	ui := newPlainUI(strings.NewReader("\ny\n"), &bytes.Buffer{})
+	result := reviewCards(ui, ff, state, false)
+	if result.Stopped == "" || result.Remaining != 1 {
+		t.Errorf("stopped %q with %d cards remaining, want 1", result.Stopped, result.Remaining)
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testScreen is a small simulated screen. Like a terminal, it can be
+// finished more than once, and it keeps what was on it when finished.
+type testScreen struct {
+	tcell.SimulationScreen
+	finished bool
+	final    string
+}
+
+func newTestScreen(t *testing.T) *testScreen {
+	t.Helper()
+	sim := tcell.NewSimulationScreen("UTF-8")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	sim.SetSize(80, 24)
+	feedbackDelay = 0
+	return &testScreen{SimulationScreen: sim}
+}
+
+func (s *testScreen) Fini() {
+	if !s.finished {
+		s.finished = true
+		s.final = s.text()
+		s.SimulationScreen.Fini()
+	}
+}
+
+// text returns what is shown on the screen, one line per row without
+// trailing spaces.
+func (s *testScreen) text() string {
+	cells, width, height := s.GetContents()
+	var b strings.Builder
+	for y := 0; y < height; y++ {
+		var line strings.Builder
+		for x := 0; x < width; x++ {
+			cell := cells[y*width+x]
+			if len(cell.Runes) == 0 || cell.Runes[0] == 0 {
+				line.WriteByte(' ')
+				continue
+			}
+			line.WriteString(string(cell.Runes))
+			x += max(runewidth.RuneWidth(cell.Runes[0]), 1) - 1
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// press types keys in the background as the screen reads them. Keys are
+// single characters or names like "enter", "esc" and "backspace".
+func (s *testScreen) press(keys ...string) {
+	go func() {
+		for _, key := range keys {
+			switch key {
+			case "enter":
+				s.InjectKey(tcell.KeyEnter, '\r', tcell.ModNone)
+			case "esc":
+				s.InjectKey(tcell.KeyEscape, 0, tcell.ModNone)
+			case "backspace":
+				s.InjectKey(tcell.KeyBackspace2, 0, tcell.ModNone)
+			case "space":
+				s.InjectKey(tcell.KeyRune, ' ', tcell.ModNone)
+			default:
+				for _, r := range key {
+					s.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+				}
+			}
+		}
+	}()
+}
+
+// within runs fn and fails the test if it is still waiting for keys after
+// a few seconds.
+func within(t *testing.T, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("screen still waiting for input")
+	}
+}
+
+// checkGolden compares a screen with testdata/NAME.golden. Run the tests
+// with -update to write the golden files after changing a screen.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("screen does not match %s, got:\n%s", path, got)
+	}
+}
+
+func TestTitlePage(t *testing.T) {
+	screen := newTestScreen(t)
+	ff := &FlashFile{Title: "Spanish\nDays of the week"}
+
+	screen.press("x", "enter")
+	var nav navResult
+	within(t, func() { nav = showTitlePage(screen, ff) })
+	if nav != navNext {
+		t.Errorf("showTitlePage returned %v on enter, want navNext", nav)
+	}
+	checkGolden(t, "title_page", screen.text())
+
+	screen.press("esc")
+	within(t, func() { nav = showTitlePage(screen, ff) })
+	if nav != navBack {
+		t.Errorf("showTitlePage returned %v on esc, want navBack", nav)
+	}
+
+	screen.press("q")
+	within(t, func() { nav = showTitlePage(screen, ff) })
+	if nav != navQuit {
+		t.Errorf("showTitlePage returned %v on q, want navQuit", nav)
+	}
+}
+
+func TestFileSelection(t *testing.T) {
+	screen := newTestScreen(t)
+	files := []FlashFile{
+		{Filename: "spanish.flsh", Title: "Spanish"},
+		{Filename: "go.flsh", Title: "Go\nStandard library"},
+		{Filename: "chem.flsh", Title: "Chemistry"},
+	}
+
+	screen.press("j", "j", "k", "enter")
+	var selected *FlashFile
+	within(t, func() { selected = showFileSelection(screen, files) })
+	if selected == nil || selected.Filename != "go.flsh" {
+		t.Fatalf("selected %v, want go.flsh", selected)
+	}
+	checkGolden(t, "file_selection", screen.text())
+
+	screen.press("3")
+	within(t, func() { selected = showFileSelection(screen, files) })
+	if selected == nil || selected.Filename != "chem.flsh" {
+		t.Fatalf("selected %v, want chem.flsh", selected)
+	}
+}
+
+func TestShowCard(t *testing.T) {
+	card := Flashcard{Front: "# Lunes\nWhich day?", Back: "**Monday**", Tags: []string{"days"}}
+
+	t.Run("front", func(t *testing.T) {
+		screen := newTestScreen(t)
+		c := card
+		screen.press("q")
+		var result cardResult
+		within(t, func() { result, _, _ = showCard(screen, &c, 0) })
+		if result != cardQuit {
+			t.Errorf("result %v, want cardQuit", result)
+		}
+		checkGolden(t, "card_front", screen.text())
+	})
+
+	t.Run("correct", func(t *testing.T) {
+		screen := newTestScreen(t)
+		c := card
+		screen.press("space", "y")
+		var result cardResult
+		within(t, func() { result, _, _ = showCard(screen, &c, 0) })
+		if result != cardCorrect {
+			t.Errorf("result %v, want cardCorrect", result)
+		}
+		checkGolden(t, "card_correct", screen.text())
+	})
+
+	t.Run("wrong", func(t *testing.T) {
+		screen := newTestScreen(t)
+		c := card
+		screen.press("space", "n")
+		var result cardResult
+		within(t, func() { result, _, _ = showCard(screen, &c, 0) })
+		if result != cardWrong {
+			t.Errorf("result %v, want cardWrong", result)
+		}
+		checkGolden(t, "card_wrong", screen.text())
+	})
+}
+
+func TestMultilineInput(t *testing.T) {
+	screen := newTestScreen(t)
+	screen.press("h", "o", "l", "x", "backspace", "a", "enter")
+	var text string
+	within(t, func() {
+		text = getMultilineInput(screen, 2, "please write card front:", "press Enter to continue")
+	})
+	if text != "hola" {
+		t.Errorf("got %q, want %q", text, "hola")
+	}
+	checkGolden(t, "multiline_input", screen.text())
+
+	screen.press("a", "esc")
+	within(t, func() {
+		text = getMultilineInput(screen, 2, "please write card back:", "press Enter to preview")
+	})
+	if text != "" {
+		t.Errorf("got %q after esc, want nothing", text)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	screen := newTestScreen(t)
+	screen.press("x")
+	within(t, func() { showHelp(screen, pickerActions) })
+	checkGolden(t, "help", screen.text())
+}
+
+// writeDeck writes a deck to a temporary directory and returns its path.
+func writeDeck(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deck.flsh")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testDeck = `###
+Spanish
+###
+&&&
+&&&
+***
+!FRONT
+lunes
+!BACK
+Monday
+***
+***
+!FRONT
+martes
+!BACK
+Tuesday
+***
+`
+
+func TestReviewSession(t *testing.T) {
+	path := writeDeck(t, testDeck)
+	screen := newTestScreen(t)
+	var out bytes.Buffer
+	a := &app{openScreen: func() (tcell.Screen, error) { return screen, nil }, out: &out}
+
+	// Title page, one card right and one wrong, then leave the score screen
+	screen.press("enter", "space", "y", "space", "n", "x")
+	within(t, func() {
+		if err := a.studyDeck(path, dueMode(), defaultReviewOptions()); err != nil {
+			t.Error(err)
+		}
+	})
+	if out.String() != "1/2\n" {
+		t.Errorf("printed %q, want the score 1/2", out.String())
+	}
+
+	ff, err := parseFlashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006/01/02")
+	if ff.Cards[0].Reviewed != today+" Y" || ff.Cards[1].Reviewed != today+" N" {
+		t.Errorf("reviews %q and %q, want %s Y and N", ff.Cards[0].Reviewed, ff.Cards[1].Reviewed, today)
+	}
+	if !strings.HasSuffix(ff.Stats, "1/2") {
+		t.Errorf("stats %q do not end with the score", ff.Stats)
+	}
+}
+
+func TestStepBack(t *testing.T) {
+	t.Run("picked", func(t *testing.T) {
+		path := writeDeck(t, testDeck)
+		screen := newTestScreen(t)
+		a := &app{openScreen: func() (tcell.Screen, error) { return screen, nil }, out: &bytes.Buffer{}}
+		opts := defaultReviewOptions()
+		opts.Picked = true
+
+		// Grade the first card, step back from the second to the title
+		// page and from there to the deck picker
+		screen.press("enter", "space", "y", "esc", "esc")
+		var err error
+		within(t, func() { err = a.studyDeck(path, dueMode(), opts) })
+		if !errors.Is(err, errBack) {
+			t.Errorf("got %v, want errBack", err)
+		}
+		ff, err := parseFlashFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ff.Cards[0].Reviewed == "" {
+			t.Error("grade given before stepping back was not saved")
+		}
+	})
+
+	t.Run("command line", func(t *testing.T) {
+		path := writeDeck(t, testDeck)
+		screen := newTestScreen(t)
+		a := &app{openScreen: func() (tcell.Screen, error) { return screen, nil }, out: &bytes.Buffer{}}
+
+		// Without a deck picker to return to, esc on the title page does
+		// nothing and only q leaves
+		screen.press("esc", "enter", "esc", "q")
+		var err error
+		within(t, func() { err = a.studyDeck(path, dueMode(), defaultReviewOptions()) })
+		if err != nil {
+			t.Errorf("got %v, want no error", err)
+		}
+	})
+}
+
+func TestFlagBeforeQuitting(t *testing.T) {
+	path := writeDeck(t, testDeck)
+	screen := newTestScreen(t)
+	a := &app{openScreen: func() (tcell.Screen, error) { return screen, nil }, out: &bytes.Buffer{}}
+
+	// Flag the first card and quit without grading it
+	screen.press("enter", "f", "q")
+	within(t, func() {
+		if err := a.studyDeck(path, dueMode(), defaultReviewOptions()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	ff, err := parseFlashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ff.Cards[0].Flagged {
+		t.Error("flag set before quitting was not saved")
+	}
+}
+
+func TestTagBeforeQuitting(t *testing.T) {
+	path := writeDeck(t, testDeck)
+	screen := newTestScreen(t)
+	a := &app{openScreen: func() (tcell.Screen, error) { return screen, nil }, out: &bytes.Buffer{}}
+
+	// Tag the first card and quit without grading it
+	screen.press("enter", ":", "tag verbs", "enter", "q")
+	within(t, func() {
+		if err := a.studyDeck(path, dueMode(), defaultReviewOptions()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	ff, err := parseFlashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ff.Cards[0].hasTag("verbs") {
+		t.Errorf("tags %q, want the tag added before quitting", ff.Cards[0].Tags)
+	}
+}
+
+func TestAddFlashcard(t *testing.T) {
+	path := writeDeck(t, testDeck)
+	screen := newTestScreen(t)
+	a := &app{openScreen: func() (tcell.Screen, error) { return screen, nil }, out: &bytes.Buffer{}}
+
+	// Front, back, then save the preview
+	screen.press("miércoles", "enter", "Wednesday", "enter", "enter")
+	within(t, func() {
+		if err := a.addFlashcard(path); err != nil {
+			t.Error(err)
+		}
+	})
+	checkGolden(t, "add_preview", screen.final)
+
+	ff, err := parseFlashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ff.Cards) != 3 {
+		t.Fatalf("%d cards after adding one, want 3", len(ff.Cards))
+	}
+	if card := ff.Cards[2]; strings.TrimSpace(card.Front) != "miércoles" || strings.TrimSpace(card.Back) != "Wednesday" {
+		t.Errorf("added card %q / %q, want miércoles / Wednesday", card.Front, card.Back)
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	path := writeDeck(t, testDeck)
+	screen := newTestScreen(t)
+	a := &app{openScreen: func() (tcell.Screen, error) { return screen, nil }, out: &bytes.Buffer{}}
+
+	screen.press("l", "q")
+	within(t, func() {
+		if err := a.browseDeck(path); err != nil {
+			t.Error(err)
+		}
+	})
+	checkGolden(t, "browse", screen.final)
+}
+
+func TestScoreScreen(t *testing.T) {
+	screen := newTestScreen(t)
+	ff := &FlashFile{Stats: "2024/01/01 09:00    1/4\n2024/01/02 09:00    3/4\n2024/01/03 09:00    2/2"}
+
+	screen.press("x")
+	within(t, func() {
+		showScoreScreen(screen, ff, "2024/01/03 09:00    2/2", "Stopped: 2-card limit reached, 1 cards remaining")
+	})
+	checkGolden(t, "score", screen.text())
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webFiles is the browser app served by flash serve
+//
+//go:embed web
+var webFiles embed.FS
+
+// server serves the decks of one directory to the web app. Every change
+// reads the deck, applies the change and saves it while holding the deck's
+// lock, so that it cannot overwrite a review running in the terminal.
+type server struct {
+	dir string
+	mu  sync.Mutex // One change at a time within the server
+}
+
+// deckInfo is a deck as the web app lists it
+type deckInfo struct {
+	Name  string `json:"name"` // File name within the served directory
+	Title string `json:"title"`
+	Cards int    `json:"cards"`
+	Due   int    `json:"due"`
+}
+
+// cardInfo is a card as the web app sees it
+type cardInfo struct {
+	Number      int      `json:"number"` // Position in the deck, counting from 1
+	Front       string   `json:"front"`
+	Back        string   `json:"back"`
+	Tags        []string `json:"tags"`
+	Reviews     []string `json:"reviews"` // "2006/01/02 Y" or "... N", oldest first
+	New         bool     `json:"new"`
+	Suspended   bool     `json:"suspended"`
+	BuriedUntil string   `json:"buried_until,omitempty"`
+	Flagged     bool     `json:"flagged"`
+	Leech       bool     `json:"leech"`
+	ETag        string   `json:"etag"` // Changes whenever the card does, for If-Match
+}
+
+// httpError is an error with the HTTP status to answer it with
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e httpError) Error() string {
+	return e.msg
+}
+
+func newCardInfo(number int, card *Flashcard) cardInfo {
+	info := cardInfo{
+		Number:    number,
+		Front:     strings.TrimSpace(card.Front),
+		Back:      strings.TrimSpace(card.Back),
+		Tags:      append([]string{}, card.Tags...),
+		Reviews:   []string{},
+		New:       card.isNew(),
+		Suspended: card.Suspended,
+		Flagged:   card.Flagged,
+		Leech:     card.isLeech(),
+		ETag:      cardETag(card),
+	}
+	// A burial that has run out no longer hides the card
+	if card.isBuried(time.Now()) {
+		info.BuriedUntil = card.BuriedUntil
+	}
+	for _, review := range strings.Split(card.Reviewed, "\n") {
+		if review = strings.TrimSpace(review); review != "" {
+			info.Reviews = append(info.Reviews, review)
+		}
+	}
+	return info
+}
+
+// cardETag returns a tag for the content and state of a card, so that a
+// client can tell whether the card it read is still the one at its number.
+func cardETag(card *Flashcard) string {
+	h := sha256.New()
+	for _, part := range []string{card.Front, card.Back, strings.Join(card.Tags, " "), card.Reviewed, formatCardState(*card)} {
+		io.WriteString(h, strings.TrimSpace(part))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum(nil)[:8])
+}
+
+// checkIfMatch refuses a change to a card unless it still matches the
+// request's If-Match header. Requests without one change the card as it is.
+func checkIfMatch(r *http.Request, idx int, card *Flashcard) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+	etag := cardETag(card)
+	for _, want := range strings.Split(header, ",") {
+		if want = strings.TrimSpace(want); want == "*" || want == etag {
+			return nil
+		}
+	}
+	return httpError{http.StatusPreconditionFailed, fmt.Sprintf("card %d has changed since it was read", idx+1)}
+}
+
+// dueCards returns the cards a review would show now, with the deck's
+// daily new-card limit applied.
+func dueCards(ff *FlashFile) []int {
+	indices, _ := limitNewCards(ff, availableCards(ff))
+	return indices
+}
+
+func newDeckInfo(ff *FlashFile) deckInfo {
+	return deckInfo{
+		Name:  filepath.Base(ff.Filename),
+		Title: strings.TrimSpace(ff.Title),
+		Cards: len(ff.Cards),
+		Due:   len(dueCards(ff)),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with {"error": "..."} and the error's status, or 500.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he httpError
+	switch {
+	case errors.As(err, &he):
+		status = he.status
+	case errors.Is(err, errDeckLocked):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// readBody decodes a JSON request body into v. The body must be sent as
+// JSON: a web page on another site can only send other types to the API
+// without the browser asking the server first.
+func readBody(r *http.Request, v any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return httpError{http.StatusUnsupportedMediaType, "request body must be sent as application/json"}
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return httpError{http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err)}
+	}
+	return nil
+}
+
+// deckPath returns the file of the deck named in the request. Only decks
+// directly inside the served directory can be named.
+func (s *server) deckPath(r *http.Request) (string, error) {
+	name := r.PathValue("deck")
+	if name != filepath.Base(name) || filepath.Ext(name) != ".flsh" || strings.HasPrefix(name, ".") {
+		return "", httpError{http.StatusNotFound, fmt.Sprintf("no deck %q", name)}
+	}
+	path := filepath.Join(s.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", httpError{http.StatusNotFound, fmt.Sprintf("no deck %q", name)}
+	}
+	return path, nil
+}
+
+// readDeck reads the deck named in the request.
+func (s *server) readDeck(r *http.Request) (*FlashFile, error) {
+	path, err := s.deckPath(r)
+	if err != nil {
+		return nil, err
+	}
+	ff, err := parseFlashFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+	return ff, nil
+}
+
+// changeDeck applies change to the deck named in the request under the
+// deck's lock and saves it if change succeeds. It answers with what change
+// returns, and returns the deck's path if it was saved.
+func (s *server) changeDeck(w http.ResponseWriter, r *http.Request, change func(ff *FlashFile) (any, error)) (string, bool) {
+	path, err := s.deckPath(r)
+	if err != nil {
+		writeError(w, err)
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockDeck(path)
+	if err != nil {
+		writeError(w, err)
+		return "", false
+	}
+	defer unlock()
+
+	ff, err := parseFlashFile(path)
+	if err != nil {
+		writeError(w, fmt.Errorf("error reading file: %v", err))
+		return "", false
+	}
+	result, err := change(ff)
+	if err != nil {
+		writeError(w, err)
+		return "", false
+	}
+	if err := saveFlashFile(ff); err != nil {
+		writeError(w, err)
+		return "", false
+	}
+	writeJSON(w, http.StatusOK, result)
+	return path, true
+}
+
+// cardNumber returns the index of the card numbered in the request.
+func cardNumber(r *http.Request, ff *FlashFile) (int, error) {
+	n, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil || n < 1 || n > len(ff.Cards) {
+		return 0, httpError{http.StatusNotFound, fmt.Sprintf("no card %s", r.PathValue("number"))}
+	}
+	return n - 1, nil
+}
+
+func (s *server) listDecks(w http.ResponseWriter, r *http.Request) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.flsh"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sort.Strings(files)
+
+	decks := []deckInfo{}
+	for _, file := range files {
+		ff, err := parseFlashFile(file)
+		if err != nil {
+			log.Printf("Error reading %s: %v\n", file, err)
+			continue
+		}
+		decks = append(decks, newDeckInfo(ff))
+	}
+	writeJSON(w, http.StatusOK, decks)
+}
+
+func (s *server) getDeck(w http.ResponseWriter, r *http.Request) {
+	ff, err := s.readDeck(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	cards := []cardInfo{}
+	for i := range ff.Cards {
+		cards = append(cards, newCardInfo(i+1, &ff.Cards[i]))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"deck": newDeckInfo(ff), "cards": cards})
+}
+
+func (s *server) getDue(w http.ResponseWriter, r *http.Request) {
+	ff, err := s.readDeck(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	cards := []cardInfo{}
+	for _, idx := range dueCards(ff) {
+		cards = append(cards, newCardInfo(idx+1, &ff.Cards[idx]))
+	}
+	writeJSON(w, http.StatusOK, cards)
+}
+
+// gradeCard records a grade like a review in the terminal: the review
+// history is updated and a failed card may become a leech.
+func (s *server) gradeCard(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Correct *bool `json:"correct"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Correct == nil {
+		writeError(w, httpError{http.StatusBadRequest, `"correct" is required`})
+		return
+	}
+
+	var leech *cardInfo
+	path, ok := s.changeDeck(w, r, func(ff *FlashFile) (any, error) {
+		idx, err := cardNumber(r, ff)
+		if err != nil {
+			return nil, err
+		}
+		card := &ff.Cards[idx]
+		if err := checkIfMatch(r, idx, card); err != nil {
+			return nil, err
+		}
+		if !card.isAvailable(time.Now()) {
+			return nil, httpError{http.StatusConflict, fmt.Sprintf("card %d is suspended or buried", idx+1)}
+		}
+		recordReview(card, *body.Correct)
+		becameLeech := !*body.Correct && applyLeech(ff, card)
+		info := newCardInfo(idx+1, card)
+		if becameLeech {
+			leech = &info
+		}
+		return map[string]any{"card": info, "became_leech": leech != nil}, nil
+	})
+	if ok && leech != nil {
+		reportHook("on-leech", path, hookInput{Card: leech})
+	}
+}
+
+// recordSession adds the score of a finished web review to the deck's
+// stats, as the terminal does at the end of a session.
+func (s *server) recordSession(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Correct int `json:"correct"`
+		Total   int `json:"total"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Total < 1 || body.Correct < 0 || body.Correct > body.Total {
+		writeError(w, httpError{http.StatusBadRequest, "need 0 <= correct <= total and total >= 1"})
+		return
+	}
+
+	path, ok := s.changeDeck(w, r, func(ff *FlashFile) (any, error) {
+		entry := recordSessionScore(ff, &sessionState{Correct: body.Correct, Total: body.Total})
+		return map[string]string{"stats_entry": entry}, nil
+	})
+	if ok {
+		summary := &sessionSummary{Mode: "web", Correct: body.Correct, Total: body.Total, Missed: []int{}}
+		reportHook("post-session", path, hookInput{Session: summary})
+	}
+}
+
+// sameOrigin refuses requests that change decks when they come from a web
+// page on another site. Browsers send Origin with such requests; other
+// clients, like curl, leave it out.
+func sameOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeError(w, httpError{http.StatusForbidden, fmt.Sprintf("requests from %s are not allowed", origin)})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routes returns the handler for the web app and the JSON API described in
+// API.md.
+func (s *server) routes() http.Handler {
+	web, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServer(http.FS(web)))
+	mux.HandleFunc("GET /api/decks", s.listDecks)
+	mux.HandleFunc("GET /api/decks/{deck}", s.getDeck)
+	mux.HandleFunc("GET /api/decks/{deck}/due", s.getDue)
+	mux.HandleFunc("GET /api/decks/{deck}/stats", s.getStats)
+	mux.HandleFunc("GET /api/decks/{deck}/cards", s.listCards)
+	mux.HandleFunc("POST /api/decks/{deck}/cards", s.addCard)
+	mux.HandleFunc("GET /api/decks/{deck}/cards/{number}", s.getCard)
+	mux.HandleFunc("PUT /api/decks/{deck}/cards/{number}", s.editCard)
+	mux.HandleFunc("DELETE /api/decks/{deck}/cards/{number}", s.deleteCard)
+	mux.HandleFunc("POST /api/decks/{deck}/cards/{number}/grade", s.gradeCard)
+	mux.HandleFunc("POST /api/decks/{deck}/sessions", s.recordSession)
+	return sameOrigin(mux)
+}
+
+// serveDecks serves the decks in dir to the web app on addr until it fails.
+func serveDecks(addr, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return usagef("%s is not a directory", dir)
+	}
+
+	s := &server{dir: dir}
+	log.Printf("Serving the decks in %s at http://%s/\n", dir, addr)
+	return http.ListenAndServe(addr, s.routes())
+}
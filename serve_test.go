@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCrossSiteChanges(t *testing.T) {
+	path := writeDeck(t, testDeck)
+	s := &server{dir: filepath.Dir(path)}
+	handler := s.routes()
+
+	tests := []struct {
+		name, contentType, origin string
+		want                      int
+	}{
+		{"form from another site", "text/plain", "http://evil.example", http.StatusForbidden},
+		{"plain text", "text/plain", "", http.StatusUnsupportedMediaType},
+		{"no content type", "", "", http.StatusUnsupportedMediaType},
+		{"json from another site", "application/json", "http://evil.example", http.StatusForbidden},
+		{"json from a null origin", "application/json", "null", http.StatusForbidden},
+		{"json from the web app", "application/json; charset=utf-8", "http://example.com", http.StatusOK},
+		{"json without origin", "application/json", "", http.StatusOK},
+	}
+	added := 0
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "http://example.com/api/decks/deck.flsh/cards",
+				strings.NewReader(`{"front": "jueves", "back": "Thursday"}`))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != test.want {
+				t.Errorf("answered %d %s, want %d", w.Code, w.Body, test.want)
+			}
+			if w.Code == http.StatusOK {
+				added++
+			}
+
+			ff, err := parseFlashFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ff.Cards) != 2+added {
+				t.Errorf("deck has %d cards, want %d", len(ff.Cards), 2+added)
+			}
+		})
+	}
+
+	// Reading needs no checks, as other sites cannot see the answer
+	r := httptest.NewRequest("GET", "http://example.com/api/decks/deck.flsh/cards", nil)
+	r.Header.Set("Origin", "http://evil.example")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET answered %d, want 200", w.Code)
+	}
+}
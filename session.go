@@ -0,0 +1,582 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// relearnGap is the minimum number of cards shown before a failed card
+// comes back in the same session.
+const relearnGap = 3
+
+// reviewOptions controls how a review session presents its cards
+type reviewOptions struct {
+	Relearn   bool            `json:"relearn"`   // Requeue failed cards until answered correctly
+	Steps     []time.Duration `json:"steps"`     // Learning steps for requeued cards
+	Shuffle   bool            `json:"shuffle"`   // Present cards in random order
+	Seed      int64           `json:"seed"`      // Shuffle seed, random if 0
+	Countdown time.Duration   `json:"countdown"` // Time allowed per card front, 0 if untimed
+	Limit     int             `json:"limit"`     // Stop after this many graded cards, 0 for no limit
+	Minutes   int             `json:"minutes"`   // Stop after this many minutes, 0 for no limit
+	Resume    bool            `json:"-"`         // Continue the unfinished session without asking
+	DryRun    bool            `json:"-"`         // Grades stay in memory, no leech handling
+	Plain     bool            `json:"-"`         // Review with plain lines on stdin and stdout
+	Picked    bool            `json:"-"`         // Chosen in the deck picker, which the title page can step back to
+}
+
+// defaultReviewOptions returns the review options from the config file,
+// which the review flags then override.
+func defaultReviewOptions() reviewOptions {
+	opts := cfg.Review
+	opts.Steps = append([]time.Duration(nil), cfg.Review.Steps...)
+	return opts
+}
+
+// stepsFlag parses learning steps given as "1m,10m"
+type stepsFlag struct {
+	steps *[]time.Duration
+}
+
+func (f stepsFlag) String() string {
+	if f.steps == nil {
+		return ""
+	}
+	var parts []string
+	for _, step := range *f.steps {
+		parts = append(parts, step.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f stepsFlag) Set(value string) error {
+	var steps []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		step, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || step < 0 {
+			return fmt.Errorf("invalid learning step %q", part)
+		}
+		steps = append(steps, step)
+	}
+	*f.steps = steps
+	return nil
+}
+
+// addReviewFlags adds the review flags to fs, bound to opts.
+func addReviewFlags(fs *flag.FlagSet, opts *reviewOptions) {
+	fs.BoolVar(&opts.Relearn, "relearn", opts.Relearn, "repeat failed cards until answered correctly")
+	fs.Var(stepsFlag{&opts.Steps}, "steps", "learning steps for repeated cards, e.g. 1m,10m")
+	fs.BoolVar(&opts.Shuffle, "shuffle", opts.Shuffle, "present cards in random order")
+	fs.Int64Var(&opts.Seed, "seed", opts.Seed, "seed for --shuffle")
+	fs.IntVar(&opts.Limit, "limit", opts.Limit, "stop after this many cards")
+	fs.IntVar(&opts.Minutes, "minutes", opts.Minutes, "stop after this many minutes")
+	fs.BoolVar(&opts.Plain, "plain", opts.Plain, "read and print plain lines instead of using the full screen")
+}
+
+// parseInterspersed parses fs from args while allowing flags to follow
+// positional arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// recordReview appends today's grade to the card's review history.
+func recordReview(card *Flashcard, correct bool) {
+	if card.Reviewed != "" {
+		card.Reviewed += "\n"
+	}
+	grade := " N"
+	if correct {
+		grade = " Y"
+	}
+	card.Reviewed += time.Now().Format("2006/01/02") + grade
+}
+
+// relearnCard is a failed card waiting to be shown again
+type relearnCard struct {
+	index int       // Index into FlashFile.Cards
+	step  int       // Current learning step
+	due   time.Time // Not shown again before this time...
+	after int       // ...or before this many cards have been shown
+}
+
+// reviewResult summarizes a run of reviewCards
+type reviewResult struct {
+	Changed   bool          // Something in the file needs saving
+	Stopped   string        // Why the session ended early because of a limit, if it did
+	Remaining int           // Cards left unseen when a limit was reached
+	Missed    []int         // Cards whose first answer was wrong
+	Latency   time.Duration // Total time taken to reveal the graded cards
+	TimedOut  int           // Cards that ran out of time
+	Leeches   []int         // Cards that became leeches
+	Back      bool          // The user stepped back to the title page
+}
+
+// reviewCards shows the session's cards in order, starting at state.Index
+// and adding to the running score in state. Only the first answer to each
+// card is recorded and counted; with the relearn option, failed cards are
+// requeued until they are answered correctly. Stepping back from a card
+// ends the session like quitting if canGoBack is set, and is ignored
+// otherwise.
+func reviewCards(ui sessionUI, ff *FlashFile, state *sessionState, canGoBack bool) reviewResult {
+	var result reviewResult
+	var learning []relearnCard
+	opts := state.Options
+	shown := 0
+	graded := 0
+
+	var deadline time.Time
+	if opts.Minutes > 0 {
+		deadline = time.Now().Add(time.Duration(opts.Minutes) * time.Minute)
+	}
+
+	for state.Index < len(state.Cards) || len(learning) > 0 {
+		now := time.Now()
+
+		// Stop cleanly once a session limit is reached. The rest of the
+		// queue is dropped so that the session counts as finished.
+		if opts.Limit > 0 && graded >= opts.Limit {
+			result.Stopped = fmt.Sprintf("%d-card limit reached", opts.Limit)
+		} else if !deadline.IsZero() && !now.Before(deadline) {
+			result.Stopped = fmt.Sprintf("%d-minute limit reached", opts.Minutes)
+		}
+		if result.Stopped != "" {
+			// Cards suspended or buried since the session started would
+			// not be shown anyway
+			for _, idx := range state.Cards[state.Index:] {
+				if ff.Cards[idx].isAvailable(now) {
+					result.Remaining++
+				}
+			}
+			state.Cards = state.Cards[:state.Index]
+			break
+		}
+
+		// Pick a due relearning card first, otherwise the next new card.
+		// Once the queue is empty, remaining relearning cards are shown early.
+		pick := -1
+		for i, lc := range learning {
+			if (!now.Before(lc.due) && shown >= lc.after) || state.Index >= len(state.Cards) {
+				if pick == -1 || lc.due.Before(learning[pick].due) {
+					pick = i
+				}
+			}
+		}
+
+		if pick == -1 {
+			idx := state.Cards[state.Index]
+			card := &ff.Cards[idx]
+			if !card.isAvailable(now) {
+				state.Index++
+				continue
+			}
+
+			answer, latency, changed := ui.Card(card, opts.Countdown)
+			if changed {
+				result.Changed = true
+			}
+			if answer == cardBack && !canGoBack {
+				continue // Show the card again
+			}
+			if answer == cardQuit || answer == cardBack {
+				// User quit early
+				result.Back = answer == cardBack
+				break
+			}
+			state.Index++
+			shown++
+			result.Changed = true
+			if answer == cardSkipped {
+				continue
+			}
+
+			state.Total++
+			graded++
+			result.Latency += latency
+			if opts.Countdown > 0 && latency >= opts.Countdown {
+				result.TimedOut++
+			}
+			recordReview(card, answer == cardCorrect)
+			if answer == cardCorrect {
+				state.Correct++
+				continue
+			}
+			result.Missed = append(result.Missed, idx)
+			if !opts.DryRun && applyLeech(ff, card) {
+				result.Leeches = append(result.Leeches, idx)
+				ui.Leech(card)
+			}
+			if opts.Relearn && card.isAvailable(time.Now()) {
+				learning = append(learning, relearnCard{
+					index: idx,
+					due:   time.Now().Add(opts.Steps[0]),
+					after: shown + relearnGap,
+				})
+			}
+			continue
+		}
+
+		// Relearning answers are not recorded or counted
+		lc := learning[pick]
+		learning = append(learning[:pick], learning[pick+1:]...)
+		answer, _, changed := ui.Card(&ff.Cards[lc.index], opts.Countdown)
+		if changed {
+			result.Changed = true
+		}
+		if answer == cardBack && !canGoBack {
+			learning = append(learning, lc)
+			continue
+		}
+		if answer == cardQuit || answer == cardBack {
+			result.Back = answer == cardBack
+			break
+		}
+		shown++
+		switch answer {
+		case cardCorrect:
+			lc.step++
+			if lc.step >= len(opts.Steps) {
+				continue // Graduated
+			}
+		case cardWrong:
+			lc.step = 0
+		default:
+			continue // Suspended or buried
+		}
+		lc.due = time.Now().Add(opts.Steps[lc.step])
+		lc.after = shown + relearnGap
+		learning = append(learning, lc)
+	}
+
+	return result
+}
+
+// availableCards returns the indices of every card that may be shown now.
+func availableCards(ff *FlashFile) []int {
+	var indices []int
+	now := time.Now()
+	for i := range ff.Cards {
+		if ff.Cards[i].isAvailable(now) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// lastReviewWrong reports whether the card was failed in its last review.
+func (card *Flashcard) lastReviewWrong() bool {
+	reviews := strings.Split(card.Reviewed, "\n")
+	return strings.HasSuffix(reviews[len(reviews)-1], "N")
+}
+
+// isNew reports whether the card has never been reviewed.
+func (card *Flashcard) isNew() bool {
+	return strings.TrimSpace(card.Reviewed) == ""
+}
+
+// newCardsSeenToday counts the cards whose first review was today.
+func newCardsSeenToday(ff *FlashFile, now time.Time) int {
+	today := now.Format("2006/01/02")
+	count := 0
+	for _, card := range ff.Cards {
+		if strings.HasPrefix(strings.TrimSpace(card.Reviewed), today) {
+			count++
+		}
+	}
+	return count
+}
+
+// limitNewCards drops new cards beyond the deck's daily new-card limit
+// ("new-per-day: N" between @@@) and returns how many were held back.
+func limitNewCards(ff *FlashFile, indices []int) ([]int, int) {
+	limit := ff.intOption("new-per-day", 0)
+	if limit <= 0 {
+		return indices, 0
+	}
+
+	allowed := limit - newCardsSeenToday(ff, time.Now())
+	var kept []int
+	held := 0
+	for _, idx := range indices {
+		if ff.Cards[idx].isNew() {
+			if allowed <= 0 {
+				held++
+				continue
+			}
+			allowed--
+		}
+		kept = append(kept, idx)
+	}
+	return kept, held
+}
+
+// remainingSummary describes what was left out of a session, or returns
+// an empty string if nothing was.
+func remainingSummary(state *sessionState, result reviewResult) string {
+	var parts []string
+	if result.Stopped != "" {
+		parts = append(parts, fmt.Sprintf("Stopped: %s, %d cards remaining", result.Stopped, result.Remaining))
+	}
+	if state.HeldBack > 0 {
+		parts = append(parts, fmt.Sprintf("%d new cards held back by the daily limit", state.HeldBack))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// sessionMode is one way of studying a deck. The mode chooses the cards and
+// how the session is recorded and summed up; runSession does the rest.
+type sessionMode struct {
+	Name      string // Saved with the session state, e.g. "all" or "cram"
+	Empty     string // Printed when there are no cards to study
+	TitlePage bool   // Show the deck's title page first
+	Resumable bool   // Save the session when interrupted and offer to resume it
+	Stats     bool   // Add the score to the deck's stats
+	Save      bool   // Write the grades and card changes to the deck
+
+	// Select returns the indices of the cards to study
+	Select func(ff *FlashFile) []int
+	// Summary shows the result of a session with graded cards. Modes
+	// without one print what a limit left out after the score instead.
+	Summary func(screen tcell.Screen, ff *FlashFile, state *sessionState, result reviewResult, score string)
+}
+
+// dueMode reviews every card that may be shown now and keeps score.
+func dueMode() sessionMode {
+	return sessionMode{
+		Name:      "all",
+		Empty:     "No cards to review",
+		TitlePage: true,
+		Resumable: true,
+		Stats:     true,
+		Save:      true,
+		Select:    availableCards,
+		Summary: func(screen tcell.Screen, ff *FlashFile, state *sessionState, result reviewResult, score string) {
+			showScoreScreen(screen, ff, score, remainingSummary(state, result))
+		},
+	}
+}
+
+// wrongMode reviews the cards failed in their last review. Only the card
+// history is updated, not the stats.
+func wrongMode() sessionMode {
+	return sessionMode{
+		Name:      "wrong",
+		Empty:     "No cards to review - all cards were correct in last review!",
+		Resumable: true,
+		Save:      true,
+		Select: func(ff *FlashFile) []int {
+			var indices []int
+			now := time.Now()
+			for i := range ff.Cards {
+				if ff.Cards[i].isAvailable(now) && ff.Cards[i].lastReviewWrong() {
+					indices = append(indices, i)
+				}
+			}
+			return indices
+		},
+	}
+}
+
+// studyDeck reads a deck and runs a session on it.
+func (a *app) studyDeck(filename string, mode sessionMode, opts reviewOptions) error {
+	ff, err := parseFlashFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	return a.runSession(ff, mode, opts)
+}
+
+// runSession studies a deck in the given mode: it selects the cards, shows
+// and grades them, saves the deck and the unfinished session, shows the
+// summary and finally prints the score.
+func (a *app) runSession(ff *FlashFile, mode sessionMode, opts reviewOptions) error {
+	// Hooks run once the deck is unlocked, so that they can run flash on it
+	unlock := func() {}
+	var hooks []func()
+	defer func() {
+		unlock()
+		for _, hook := range hooks {
+			hook()
+		}
+	}()
+
+	if mode.Save {
+		release, err := lockDeck(ff.Filename)
+		if err != nil {
+			return err
+		}
+		unlock = release
+
+		// Read the deck again now that nothing else can change it
+		ff, err = parseFlashFile(ff.Filename)
+		if err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+	}
+
+	indices := mode.Select(ff)
+	if len(indices) == 0 && !(mode.Resumable && opts.Resume) {
+		fmt.Fprintln(a.out, mode.Empty)
+		return nil
+	}
+
+	var ui sessionUI
+	if opts.Plain {
+		ui = newPlainUI(a.in, a.out)
+	} else {
+		screen, err := a.openScreen()
+		if err != nil {
+			return err
+		}
+		ui = screenUI{screen}
+	}
+
+	// Closed before the hooks run, so that they can print
+	defer ui.Close()
+
+	// Stepping back from the first card or the resume prompt shows the
+	// title page again
+	for {
+		if mode.TitlePage {
+			switch ui.TitlePage(ff) {
+			case navQuit:
+				return nil
+			case navBack:
+				if opts.Picked {
+					return errBack
+				}
+				continue // Nothing to step back to
+			}
+		}
+
+		// Pick up an unfinished session or start a new one
+		var state *sessionState
+		if mode.Resumable {
+			var nav navResult
+			state, nav = startSession(ui, ff, mode.Name, indices, opts)
+			if nav == navQuit {
+				return nil
+			}
+			if nav == navBack {
+				continue
+			}
+		} else {
+			state = newSessionState(ff, mode.Name, indices, opts)
+		}
+
+		start := time.Now()
+		result := reviewCards(ui, ff, state, mode.TitlePage)
+		duration := time.Since(start)
+
+		score := ""
+		if state.Total > 0 && mode.Stats {
+			score = recordSessionScore(ff, state)
+		}
+		if mode.Save && (state.Total > 0 || result.Changed) {
+			if err := saveFlashFile(ff); err != nil {
+				return err
+			}
+			if state.Total > 0 {
+				summary := newSessionSummary(state, result, duration)
+				hooks = append(hooks, func() {
+					reportHook("post-session", ff.Filename, hookInput{Session: summary})
+				})
+			}
+			for _, idx := range result.Leeches {
+				card := newCardInfo(idx+1, &ff.Cards[idx])
+				hooks = append(hooks, func() {
+					reportHook("on-leech", ff.Filename, hookInput{Card: &card})
+				})
+			}
+		}
+		if mode.Resumable {
+			if err := persistSession(ff, state); err != nil {
+				return fmt.Errorf("error saving session: %v", err)
+			}
+		}
+		if result.Back {
+			indices = mode.Select(ff)
+			continue
+		}
+
+		summarized := state.Total > 0 && ui.Summary(mode, ff, state, result, score)
+		ui.Close()
+		if state.Total > 0 {
+			fmt.Fprintf(a.out, "%d/%d\n", state.Correct, state.Total)
+		}
+		if remaining := remainingSummary(state, result); remaining != "" && !summarized {
+			fmt.Fprintln(a.out, remaining)
+		}
+		return nil
+	}
+}
+
+// sessionUI is how a session talks to the user: on the terminal screen, or
+// in plain lines with --plain.
+type sessionUI interface {
+	// TitlePage shows the deck's title and returns how the user left it.
+	TitlePage(ff *FlashFile) navResult
+	// Resume asks whether to continue an unfinished session. It returns
+	// navNext with the answer unless the user stepped back or quit.
+	Resume(saved *sessionState) (bool, navResult)
+	// Card shows a card and returns the grade, the time taken to reveal
+	// it and whether the card was changed without being graded, like
+	// showCard.
+	Card(card *Flashcard, countdown time.Duration) (cardResult, time.Duration, bool)
+	// Leech tells the user that a card has just become a leech.
+	Leech(card *Flashcard)
+	// Summary shows the result of a session with graded cards and reports
+	// whether it did.
+	Summary(mode sessionMode, ff *FlashFile, state *sessionState, result reviewResult, score string) bool
+	// Close gives the terminal back. It may be called more than once.
+	Close()
+}
+
+// screenUI runs a session on the terminal screen
+type screenUI struct {
+	screen tcell.Screen
+}
+
+func (u screenUI) TitlePage(ff *FlashFile) navResult {
+	return showTitlePage(u.screen, ff)
+}
+
+func (u screenUI) Resume(saved *sessionState) (bool, navResult) {
+	return promptResume(u.screen, saved)
+}
+
+func (u screenUI) Card(card *Flashcard, countdown time.Duration) (cardResult, time.Duration, bool) {
+	return showCard(u.screen, card, countdown)
+}
+
+func (u screenUI) Leech(card *Flashcard) {
+	showLeech(u.screen, card)
+}
+
+func (u screenUI) Summary(mode sessionMode, ff *FlashFile, state *sessionState, result reviewResult, score string) bool {
+	if mode.Summary == nil {
+		return false
+	}
+	mode.Summary(u.screen, ff, state, result, score)
+	return true
+}
+
+func (u screenUI) Close() {
+	u.screen.Fini()
+}
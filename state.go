@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseCardState reads one line of a card's !STATE section.
+// Recognised lines are "suspended", "flagged" and "buried YYYY/MM/DD".
+func parseCardState(card *Flashcard, line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "suspended":
+		card.Suspended = true
+	case "flagged":
+		card.Flagged = true
+	case "buried":
+		if len(fields) > 1 {
+			card.BuriedUntil = fields[1]
+		}
+	}
+}
+
+// formatCardState returns the !STATE section body for a card, or an empty
+// string if the card has no state worth saving.
+func formatCardState(card Flashcard) string {
+	var lines []string
+	if card.Suspended {
+		lines = append(lines, "suspended")
+	}
+	if card.isBuried(time.Now()) {
+		lines = append(lines, "buried "+card.BuriedUntil)
+	}
+	if card.Flagged {
+		lines = append(lines, "flagged")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isBuried reports whether the card is hidden on the given day.
+func (card *Flashcard) isBuried(now time.Time) bool {
+	return card.BuriedUntil != "" && card.BuriedUntil > now.Format("2006/01/02")
+}
+
+// bury hides the card until the day after now.
+func (card *Flashcard) bury(now time.Time) {
+	card.BuriedUntil = now.AddDate(0, 0, 1).Format("2006/01/02")
+}
+
+// isAvailable reports whether the card may be shown in a review session.
+func (card *Flashcard) isAvailable(now time.Time) bool {
+	return !card.Suspended && !card.isBuried(now)
+}
+
+// firstLine returns the first line of text, used for one-line card listings.
+func firstLine(text string) string {
+	return strings.SplitN(strings.TrimSpace(text), "\n", 2)[0]
+}
+
+// listFlaggedCards prints the flagged cards of each file.
+// With no files given, every deck findDecks finds is listed.
+func (a *app) listFlaggedCards(filenames []string) error {
+	if len(filenames) == 0 {
+		var err error
+		filenames, err = findDecks()
+		if err != nil {
+			return err
+		}
+	}
+
+	found := 0
+	for _, filename := range filenames {
+		ff, err := parseFlashFile(filename)
+		if err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+
+		header := false
+		for i, card := range ff.Cards {
+			if !card.Flagged {
+				continue
+			}
+			if !header {
+				fmt.Fprintln(a.out, filename)
+				header = true
+			}
+			fmt.Fprintf(a.out, "  %d. %s\n", i+1, firstLine(card.Front))
+			found++
+		}
+	}
+
+	if found == 0 {
+		fmt.Fprintln(a.out, "No flagged cards")
+	}
+	return nil
+}
+
+// unsuspendCards clears the suspended state of the given 1-based card
+// numbers, or of every card if no numbers are given.
+func (a *app) unsuspendCards(filename string, numbers []string) error {
+	unlock, err := lockDeck(filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ff, err := parseFlashFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+
+	count := 0
+	if len(numbers) == 0 {
+		for i := range ff.Cards {
+			if ff.Cards[i].Suspended {
+				ff.Cards[i].Suspended = false
+				count++
+			}
+		}
+	} else {
+		for _, number := range numbers {
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 1 || n > len(ff.Cards) {
+				return fmt.Errorf("invalid card number: %s", number)
+			}
+			if ff.Cards[n-1].Suspended {
+				ff.Cards[n-1].Suspended = false
+				count++
+			}
+		}
+	}
+
+	if err := saveFlashFile(ff); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Unsuspended %d card(s)\n", count)
+	return nil
+}
+
+// hasTag reports whether the card carries the given tag.
+func (card *Flashcard) hasTag(tag string) bool {
+	for _, t := range card.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// addTag adds a tag to the card unless it is already present.
+func (card *Flashcard) addTag(tag string) {
+	if !card.hasTag(tag) {
+		card.Tags = append(card.Tags, tag)
+	}
+}
+
+// intOption returns a numeric deck option, or def if it is unset or invalid.
+func (ff *FlashFile) intOption(key string, def int) int {
+	value, ok := ff.Options[key]
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// stringOption returns a deck option, or def if it is unset.
+func (ff *FlashFile) stringOption(key string, def string) string {
+	if value, ok := ff.Options[key]; ok && value != "" {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// tableColumns are the card fields a CSV or TSV file can hold:
+//
+//	front, back  the card's text; fields may span several lines
+//	tags         tags separated by spaces or commas
+//	id           the card's number in the deck, counting from 1
+//	reviews      the review history, one "2006/01/02 Y" (or N) per line
+//
+// On import a column named "-" is skipped, and a row with an id changes
+// that card instead of adding one.
+var tableColumns = []string{"front", "back", "tags", "id", "reviews"}
+
+const defaultColumns = "front,back,tags"
+
+// tableOptions are the flags shared by import and export
+type tableOptions struct {
+	Format  string   // "csv" or "tsv"
+	Columns []string // Field of each column, from tableColumns or "-"
+	Header  bool     // The first row names the columns
+}
+
+// parseColumns reads a comma-separated list of column fields.
+func parseColumns(list string) ([]string, error) {
+	var columns []string
+	for _, column := range strings.Split(list, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if column != "-" && !slices.Contains(tableColumns, column) {
+			return nil, usagef("unknown column %q, expected one of %s or -", column, strings.Join(tableColumns, ", "))
+		}
+		if column != "-" && slices.Contains(columns, column) {
+			return nil, usagef("column %q given twice", column)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// tableFormat returns the format of a file from its extension: TSV for
+// .tsv and .tab files, otherwise CSV.
+func tableFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tsv", ".tab":
+		return "tsv"
+	}
+	return "csv"
+}
+
+func checkFormat(format string) error {
+	if format != "csv" && format != "tsv" {
+		return usagef("unknown format %q, expected csv or tsv", format)
+	}
+	return nil
+}
+
+// parseTags reads tags separated by spaces or commas.
+func parseTags(field string) []string {
+	return strings.FieldsFunc(field, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// parseReviews reads a review history written one grade per line, as in
+// .flsh files. Semicolons may separate grades too, for spreadsheets that
+// make multi-line cells awkward.
+func parseReviews(field string) (string, error) {
+	var reviews []string
+	for _, review := range strings.FieldsFunc(field, func(r rune) bool { return r == '\n' || r == ';' }) {
+		review = strings.Join(strings.Fields(review), " ")
+		if review == "" {
+			continue
+		}
+		date, grade, _ := strings.Cut(review, " ")
+		if _, err := time.Parse("2006/01/02", date); err != nil || (grade != "Y" && grade != "N") {
+			return "", fmt.Errorf("invalid review %q, expected YYYY/MM/DD Y or N", review)
+		}
+		reviews = append(reviews, review)
+	}
+	return strings.Join(reviews, "\n"), nil
+}
+
+// importRow is a card read from one row of a table
+type importRow struct {
+	Line   int // Line of the row in the file, for messages
+	Number int // Card to change, or 0 to add the row as a new card
+	Card   Flashcard
+	Fields map[string]bool // The fields the row sets
+}
+
+// readTable reads the rows of a CSV or TSV file as cards. With the header
+// option and no columns given, the header names the columns, and columns
+// with other names are skipped.
+func readTable(r io.Reader, opts tableOptions) ([]importRow, error) {
+	// Spreadsheets often start UTF-8 files with a byte order mark, which
+	// would otherwise end up in the first header or field
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	if opts.Format == "tsv" {
+		reader.Comma = '\t'
+		reader.LazyQuotes = true
+	}
+
+	columns := opts.Columns
+	var rows []importRow
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if opts.Header && first {
+			if columns == nil {
+				for _, name := range record {
+					name = strings.ToLower(strings.TrimSpace(name))
+					if !slices.Contains(tableColumns, name) || slices.Contains(columns, name) {
+						name = "-"
+					}
+					columns = append(columns, name)
+				}
+			}
+			continue
+		}
+		if columns == nil {
+			columns, _ = parseColumns(defaultColumns)
+		}
+
+		row, err := parseRow(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseRow reads one row of a table. A row without an id must have a front
+// and a back, as it adds a card.
+func parseRow(record []string, columns []string) (importRow, error) {
+	row := importRow{Fields: map[string]bool{}}
+	for i, column := range columns {
+		if column == "-" {
+			continue
+		}
+		field := ""
+		if i < len(record) {
+			field = strings.ReplaceAll(record[i], "\r\n", "\n")
+		}
+
+		var err error
+		switch column {
+		case "front":
+			row.Card.Front = trimBlankLines(field)
+			err = checkCardText("front", row.Card.Front)
+		case "back":
+			row.Card.Back = trimBlankLines(field)
+			err = checkCardText("back", row.Card.Back)
+		case "tags":
+			row.Card.Tags = parseTags(field)
+		case "reviews":
+			row.Card.Reviewed, err = parseReviews(field)
+		case "id":
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			row.Number, err = strconv.Atoi(field)
+			if err != nil || row.Number < 1 {
+				err = fmt.Errorf("invalid id %q", field)
+			}
+		}
+		if err != nil {
+			return row, err
+		}
+		row.Fields[column] = true
+	}
+
+	if row.Number == 0 && (!row.Fields["front"] || !row.Fields["back"]) {
+		return row, fmt.Errorf("a new card needs a front and a back")
+	}
+	return row, nil
+}
+
+// applyRows adds and changes the cards of a deck, and returns the indices
+// of the added cards.
+func applyRows(ff *FlashFile, rows []importRow) ([]int, error) {
+	existing := len(ff.Cards)
+	var added []int
+	for _, row := range rows {
+		if row.Number == 0 {
+			ff.Cards = append(ff.Cards, row.Card)
+			added = append(added, len(ff.Cards)-1)
+			continue
+		}
+		if row.Number > existing {
+			return nil, fmt.Errorf("line %d: no card %d in %s", row.Line, row.Number, ff.Filename)
+		}
+		card := &ff.Cards[row.Number-1]
+		if row.Fields["front"] {
+			card.Front = row.Card.Front
+		}
+		if row.Fields["back"] {
+			card.Back = row.Card.Back
+		}
+		if row.Fields["tags"] {
+			card.Tags = row.Card.Tags
+		}
+		if row.Fields["reviews"] {
+			card.Reviewed = row.Card.Reviewed
+		}
+	}
+	return added, nil
+}
+
+// previewLine sums up a card for the dry-run preview.
+func previewLine(text string) string {
+	line, _, more := strings.Cut(strings.TrimSpace(text), "\n")
+	if more {
+		line += " …"
+	}
+	return line
+}
+
+// importTable adds the rows of a CSV or TSV file to a deck, creating it if
+// needed. With dryRun it only prints what would change.
+func (a *app) importTable(filename, tableFile string, opts tableOptions, dryRun bool) error {
+	var r io.Reader = a.in
+	if tableFile != "-" {
+		f, err := os.Open(tableFile)
+		if err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	rows, err := readTable(r, opts)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", tableFile, err)
+	}
+
+	unlock := func() {}
+	if !dryRun {
+		release, err := lockDeck(filename)
+		if err != nil {
+			return err
+		}
+		defer release()
+		unlock = release
+	}
+
+	ff := &FlashFile{
+		Filename: filename,
+		Title:    strings.TrimSuffix(filepath.Base(filename), ".flsh"),
+	}
+	if _, err := os.Stat(filename); err == nil {
+		ff, err = parseFlashFile(filename)
+		if err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+	}
+
+	added, err := applyRows(ff, rows)
+	if err != nil {
+		return err
+	}
+	changed := len(rows) - len(added)
+
+	if dryRun {
+		for _, row := range rows {
+			action := "update"
+			if row.Number == 0 {
+				action = "add"
+			}
+			front, back := row.Card.Front, row.Card.Back
+			if row.Number > 0 {
+				front, back = ff.Cards[row.Number-1].Front, ff.Cards[row.Number-1].Back
+			}
+			fmt.Fprintf(a.out, "line %d: %s %s / %s\n", row.Line, action, previewLine(front), previewLine(back))
+		}
+		fmt.Fprintf(a.out, "Would add %d cards and update %d cards in %s\n", len(added), changed, filename)
+		return nil
+	}
+
+	if len(rows) == 0 {
+		fmt.Fprintf(a.out, "No cards in %s\n", tableFile)
+		return nil
+	}
+	if err := saveFlashFile(ff); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Added %d cards and updated %d cards in %s\n", len(added), changed, filename)
+	unlock() // So that the hooks can run flash on the deck
+	for _, idx := range added {
+		info := newCardInfo(idx+1, &ff.Cards[idx])
+		reportHook("post-add", filename, hookInput{Card: &info})
+	}
+	return nil
+}
+
+// exportTable writes the cards of a deck as CSV or TSV to a.out.
+func (a *app) exportTable(filename string, opts tableOptions) error {
+	ff, err := parseFlashFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+
+	w := csv.NewWriter(a.out)
+	if opts.Format == "tsv" {
+		w.Comma = '\t'
+	} else {
+		w.UseCRLF = true // As RFC 4180 asks
+	}
+
+	if opts.Header {
+		w.Write(opts.Columns)
+	}
+	for i, card := range ff.Cards {
+		var record []string
+		for _, column := range opts.Columns {
+			field := ""
+			switch column {
+			case "front":
+				field = trimBlankLines(card.Front)
+			case "back":
+				field = trimBlankLines(card.Back)
+			case "tags":
+				field = strings.Join(card.Tags, " ")
+			case "id":
+				field = strconv.Itoa(i + 1)
+			case "reviews":
+				field = strings.TrimSpace(card.Reviewed)
+			}
+			record = append(record, field)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
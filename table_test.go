@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReviews(t *testing.T) {
+	tests := []struct {
+		field, want string
+	}{
+		{"", ""},
+		{"2024/01/02 Y", "2024/01/02 Y"},
+		{"2024/01/02 Y\r\n\n 2024/01/05   N ", "2024/01/02 Y\n2024/01/05 N"},
+		{"2024/01/02 Y; 2024/01/05 N", "2024/01/02 Y\n2024/01/05 N"},
+	}
+	for _, test := range tests {
+		got, err := parseReviews(strings.ReplaceAll(test.field, "\r\n", "\n"))
+		if err != nil || got != test.want {
+			t.Errorf("parseReviews(%q) = %q, %v, want %q", test.field, got, err, test.want)
+		}
+	}
+
+	for _, field := range []string{"2024/01/02", "2024/01/02 yes", "02/01/2024 Y", "2024/13/01 N"} {
+		if _, err := parseReviews(field); err == nil {
+			t.Errorf("parseReviews(%q) succeeded, want an error", field)
+		}
+	}
+}
+
+func TestParseRow(t *testing.T) {
+	columns := []string{"front", "back", "-", "tags", "id"}
+
+	row, err := parseRow([]string{"\nlunes\r\nel lunes\n", "Monday", "skipped", "days, week"}, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.Card.Front != "lunes\nel lunes" || row.Card.Back != "Monday" {
+		t.Errorf("read %q / %q, want lunes\\nel lunes / Monday", row.Card.Front, row.Card.Back)
+	}
+	if !reflect.DeepEqual(row.Card.Tags, []string{"days", "week"}) {
+		t.Errorf("read tags %q, want days and week", row.Card.Tags)
+	}
+	if row.Number != 0 || row.Fields["id"] || row.Fields["-"] {
+		t.Errorf("read number %d and fields %v, want a new card", row.Number, row.Fields)
+	}
+
+	// A row with an id changes only the fields it has
+	row, err = parseRow([]string{"", "", "", "", " 3 "}, []string{"-", "-", "-", "-", "id"})
+	if err != nil || row.Number != 3 || len(row.Fields) != 1 {
+		t.Errorf("read number %d, fields %v and %v, want only id 3", row.Number, row.Fields, err)
+	}
+
+	for _, record := range [][]string{
+		{"lunes"},                               // No back for a new card
+		{"lunes", "Monday", "", "", "0"},        // Ids count from 1
+		{"lunes", "Monday", "", "", "three"},    // Not a number
+		{"lunes", "***", "", "", ""},            // Starts a section of the file
+		{"lunes", "Monday\n!FRONT", "", "", ""}, // Starts a section of the file
+	} {
+		if _, err := parseRow(record, columns); err == nil {
+			t.Errorf("parseRow(%q) succeeded, want an error", record)
+		}
+	}
+}
+
+func TestReadTable(t *testing.T) {
+	t.Run("header", func(t *testing.T) {
+		// With a byte order mark, as spreadsheets write, and unknown columns
+		input := "\xef\xbb\xbfBack,notes,Front\r\nMonday,skip me,lunes\r\n"
+		rows, err := readTable(strings.NewReader(input), tableOptions{Format: "csv", Header: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Card.Front != "lunes" || rows[0].Card.Back != "Monday" {
+			t.Errorf("read %+v, want lunes / Monday", rows)
+		}
+	})
+
+	t.Run("byte order mark without header", func(t *testing.T) {
+		rows, err := readTable(strings.NewReader("\xef\xbb\xbflunes,Monday\n"), tableOptions{Format: "csv"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Card.Front != "lunes" {
+			t.Errorf("read %+v, want the front lunes", rows)
+		}
+	})
+
+	for _, test := range []struct {
+		format, input string
+	}{
+		{"csv", "\"lunes\r\nel lunes\",Monday,days\r\nmartes,\"Tuesday\r\n\r\nel martes\",days\r\n"},
+		{"tsv", "\"lunes\nel lunes\"\tMonday\tdays\nmartes\t\"Tuesday\n\nel martes\"\tdays\n"},
+	} {
+		t.Run("multi-line "+test.format, func(t *testing.T) {
+			rows, err := readTable(strings.NewReader(test.input), tableOptions{Format: test.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 2 {
+				t.Fatalf("read %d rows, want 2", len(rows))
+			}
+			if rows[0].Card.Front != "lunes\nel lunes" || rows[1].Card.Back != "Tuesday\n\nel martes" {
+				t.Errorf("read %q and %q, want the fields' lines", rows[0].Card.Front, rows[1].Card.Back)
+			}
+			if rows[0].Line != 1 || rows[1].Line != 3 {
+				t.Errorf("rows start on lines %d and %d, want 1 and 3", rows[0].Line, rows[1].Line)
+			}
+		})
+	}
+
+	t.Run("bad row", func(t *testing.T) {
+		_, err := readTable(strings.NewReader("lunes,Monday\nmartes\n"), tableOptions{Format: "csv"})
+		if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Errorf("got error %v, want one for line 2", err)
+		}
+	})
+}
+
+func TestTableRoundTrip(t *testing.T) {
+	cards := []Flashcard{
+		{Front: "lunes\nel lunes", Back: "Monday", Tags: []string{"days"}, Reviewed: "2024/01/02 Y\n2024/01/05 N"},
+		{Front: "martes", Back: "Tuesday, \"the second\"\n\nday", Reviewed: "2024/01/03 Y"},
+		{Front: "miércoles", Back: "Wednesday\tmid-week", Tags: []string{"days", "week"}},
+	}
+	columns, _ := parseColumns("front,back,tags,reviews")
+
+	for _, format := range []string{"csv", "tsv"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			from := filepath.Join(dir, "from.flsh")
+			if err := saveFlashFile(&FlashFile{Filename: from, Title: "Spanish", Cards: cards}); err != nil {
+				t.Fatal(err)
+			}
+			opts := tableOptions{Format: format, Columns: columns, Header: true}
+
+			var table bytes.Buffer
+			if err := (&app{out: &table}).exportTable(from, opts); err != nil {
+				t.Fatal(err)
+			}
+			to := filepath.Join(dir, "to.flsh")
+			var out bytes.Buffer
+			if err := (&app{in: &table, out: &out}).importTable(to, "-", tableOptions{Format: format, Header: true}, false); err != nil {
+				t.Fatal(err)
+			}
+
+			ff, err := parseFlashFile(to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ff.Cards) != len(cards) {
+				t.Fatalf("imported %d cards, want %d", len(ff.Cards), len(cards))
+			}
+			for i, want := range cards {
+				got := ff.Cards[i]
+				if strings.TrimSpace(got.Front) != want.Front || strings.TrimSpace(got.Back) != want.Back ||
+					strings.Join(got.Tags, " ") != strings.Join(want.Tags, " ") || strings.TrimSpace(got.Reviewed) != want.Reviewed {
+					t.Errorf("card %d is %q / %q %q %q, want %q / %q %q %q", i+1,
+						got.Front, got.Back, got.Tags, got.Reviewed, want.Front, want.Back, want.Tags, want.Reviewed)
+				}
+			}
+		})
+	}
+}
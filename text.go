@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+// tabWidth is the number of spaces a tab expands to
+const tabWidth = 4
+
+// clusterWidth returns the number of cells a grapheme cluster takes up on
+// screen. Like tcell, it goes by the width of the cluster's first rune.
+func clusterWidth(runes []rune) int {
+	if len(runes) == 0 {
+		return 0
+	}
+	if w := runewidth.RuneWidth(runes[0]); w > 0 {
+		return w
+	}
+	return 1
+}
+
+// displayWidth returns the number of cells text takes up on screen.
+func displayWidth(text string) int {
+	width := 0
+	g := uniseg.NewGraphemes(text)
+	for g.Next() {
+		width += clusterWidth(g.Runes())
+	}
+	return width
+}
+
+// drawString draws a single line of text at x, y without wrapping and
+// returns the column after it. Each grapheme cluster goes into one cell,
+// with wide characters taking two.
+func drawString(screen tcell.Screen, x, y int, text string, style tcell.Style) int {
+	g := uniseg.NewGraphemes(text)
+	for g.Next() {
+		runes := g.Runes()
+		screen.SetContent(x, y, runes[0], runes[1:], style)
+		x += clusterWidth(runes)
+	}
+	return x
+}
+
+// splitIndent separates the leading whitespace of a line from the rest,
+// with tabs expanded.
+func splitIndent(line string) (string, string) {
+	line = strings.ReplaceAll(line, "\t", strings.Repeat(" ", tabWidth))
+	rest := strings.TrimLeft(line, " ")
+	return line[:len(line)-len(rest)], rest
+}
+
+// hardWrap breaks a word that is wider than width at grapheme cluster
+// boundaries.
+func hardWrap(word string, width int) []string {
+	var pieces []string
+	var current strings.Builder
+	currentWidth := 0
+
+	g := uniseg.NewGraphemes(word)
+	for g.Next() {
+		w := clusterWidth(g.Runes())
+		if currentWidth+w > width && currentWidth > 0 {
+			pieces = append(pieces, current.String())
+			current.Reset()
+			currentWidth = 0
+		}
+		current.WriteString(g.Str())
+		currentWidth += w
+	}
+	return append(pieces, current.String())
+}
+
+// dropLastCluster removes the last grapheme cluster from text.
+func dropLastCluster(text string) string {
+	end := 0
+	g := uniseg.NewGraphemes(text)
+	for g.Next() {
+		start, _ := g.Positions()
+		end = start
+	}
+	return text[:end]
+}
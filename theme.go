@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// roleStyle is how a theme draws one kind of text
+type roleStyle struct {
+	fg    tcell.Color // Used on terminals with 256 colors or more
+	basic tcell.Color // Used on terminals with 8 or 16 colors
+	bg    tcell.Color
+	attrs tcell.AttrMask
+}
+
+// theme maps each style role to how it is drawn. Roles a theme leaves out
+// use the terminal's default colors.
+type theme map[string]roleStyle
+
+// styleRoles maps role names, as used in themes and the [colors] section
+// of the config file, to the styles the screens draw with
+var styleRoles = map[string]*tcell.Style{
+	"default": &styleDefault,
+	"title":   &styleTitle,
+	"prompt":  &stylePrompt,
+	"score":   &styleScore,
+	"correct": &styleCorrect,
+	"wrong":   &styleWrong,
+	"heading": &styleHeading,
+	"code":    &styleCode,
+	"quote":   &styleQuote,
+	"keyword": &styleKeyword,
+	"string":  &styleString,
+	"comment": &styleComment,
+	"number":  &styleNumber,
+	"type":    &styleType,
+	"key":     &styleKey,
+}
+
+func roleColor(fg, basic tcell.Color, attrs tcell.AttrMask) roleStyle {
+	return roleStyle{fg: fg, basic: basic, attrs: attrs}
+}
+
+var themes = map[string]theme{
+	"dark": {
+		"title":   roleColor(tcell.ColorGreen, tcell.ColorGreen, tcell.AttrBold),
+		"prompt":  roleColor(tcell.ColorYellow, tcell.ColorYellow, 0),
+		"score":   roleColor(tcell.NewRGBColor(0, 255, 255), tcell.ColorAqua, 0),
+		"correct": roleColor(tcell.ColorGreen, tcell.ColorGreen, tcell.AttrBold),
+		"wrong":   roleColor(tcell.ColorRed, tcell.ColorRed, tcell.AttrBold),
+		"heading": roleColor(tcell.ColorGreen, tcell.ColorGreen, tcell.AttrBold),
+		"code":    roleColor(tcell.ColorOrange, tcell.ColorOlive, 0),
+		"quote":   roleColor(tcell.ColorGray, tcell.ColorGray, tcell.AttrItalic),
+		"keyword": roleColor(tcell.ColorFuchsia, tcell.ColorFuchsia, tcell.AttrBold),
+		"string":  roleColor(tcell.ColorGreen, tcell.ColorGreen, 0),
+		"comment": roleColor(tcell.ColorGray, tcell.ColorGray, tcell.AttrItalic),
+		"number":  roleColor(tcell.ColorAqua, tcell.ColorAqua, 0),
+		"type":    roleColor(tcell.ColorDodgerBlue, tcell.ColorBlue, 0),
+		"key":     roleColor(tcell.ColorDodgerBlue, tcell.ColorBlue, tcell.AttrBold),
+	},
+
+	// Darker colors for terminals with a light background
+	"light": {
+		"title":   roleColor(tcell.ColorDarkGreen, tcell.ColorGreen, tcell.AttrBold),
+		"prompt":  roleColor(tcell.ColorDarkGoldenrod, tcell.ColorOlive, 0),
+		"score":   roleColor(tcell.ColorTeal, tcell.ColorTeal, 0),
+		"correct": roleColor(tcell.ColorDarkGreen, tcell.ColorGreen, tcell.AttrBold),
+		"wrong":   roleColor(tcell.ColorDarkRed, tcell.ColorMaroon, tcell.AttrBold),
+		"heading": roleColor(tcell.ColorDarkGreen, tcell.ColorGreen, tcell.AttrBold),
+		"code":    roleColor(tcell.ColorSaddleBrown, tcell.ColorMaroon, 0),
+		"quote":   roleColor(tcell.ColorDimGray, tcell.ColorGray, tcell.AttrItalic),
+		"keyword": roleColor(tcell.ColorPurple, tcell.ColorPurple, tcell.AttrBold),
+		"string":  roleColor(tcell.ColorDarkGreen, tcell.ColorGreen, 0),
+		"comment": roleColor(tcell.ColorDimGray, tcell.ColorGray, tcell.AttrItalic),
+		"number":  roleColor(tcell.ColorTeal, tcell.ColorTeal, 0),
+		"type":    roleColor(tcell.ColorNavy, tcell.ColorNavy, 0),
+		"key":     roleColor(tcell.ColorNavy, tcell.ColorNavy, tcell.AttrBold),
+	},
+
+	// Bright bold colors on black
+	"high-contrast": {
+		"default": {fg: tcell.ColorWhite, basic: tcell.ColorWhite, bg: tcell.ColorBlack},
+		"title":   roleColor(tcell.ColorYellow, tcell.ColorYellow, tcell.AttrBold),
+		"prompt":  roleColor(tcell.ColorWhite, tcell.ColorWhite, tcell.AttrBold),
+		"score":   roleColor(tcell.ColorAqua, tcell.ColorAqua, tcell.AttrBold),
+		"correct": roleColor(tcell.ColorLime, tcell.ColorLime, tcell.AttrBold),
+		"wrong":   roleColor(tcell.ColorRed, tcell.ColorRed, tcell.AttrBold|tcell.AttrReverse),
+		"heading": roleColor(tcell.ColorYellow, tcell.ColorYellow, tcell.AttrBold|tcell.AttrUnderline),
+		"code":    roleColor(tcell.ColorAqua, tcell.ColorAqua, 0),
+		"quote":   roleColor(tcell.ColorWhite, tcell.ColorWhite, tcell.AttrItalic),
+		"keyword": roleColor(tcell.ColorYellow, tcell.ColorYellow, tcell.AttrBold),
+		"string":  roleColor(tcell.ColorLime, tcell.ColorLime, 0),
+		"comment": roleColor(tcell.ColorSilver, tcell.ColorSilver, tcell.AttrItalic),
+		"number":  roleColor(tcell.ColorAqua, tcell.ColorAqua, 0),
+		"type":    roleColor(tcell.ColorFuchsia, tcell.ColorFuchsia, 0),
+		"key":     roleColor(tcell.ColorYellow, tcell.ColorYellow, tcell.AttrBold),
+	},
+
+	// Attributes only, also used for any theme on terminals without color
+	"monochrome": {
+		"title":   {attrs: tcell.AttrBold},
+		"score":   {attrs: tcell.AttrBold},
+		"correct": {attrs: tcell.AttrBold},
+		"wrong":   {attrs: tcell.AttrReverse},
+		"heading": {attrs: tcell.AttrBold | tcell.AttrUnderline},
+		"quote":   {attrs: tcell.AttrItalic},
+		"keyword": {attrs: tcell.AttrBold},
+		"comment": {attrs: tcell.AttrDim},
+		"key":     {attrs: tcell.AttrBold},
+	},
+}
+
+// Start with the default theme until the screen says how many colors the
+// terminal has
+func init() {
+	applyTheme("dark", nil, 256)
+}
+
+// themeNames returns the names of the built-in themes in order.
+func themeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseStyleSpec reads a style override from the config file: a color
+// name or #rrggbb value, optionally "on" a background color, and any of
+// the attributes bold, dim, italic, underline and reverse.
+func parseStyleSpec(spec string) (roleStyle, error) {
+	var rs roleStyle
+	words := strings.Fields(spec)
+	for i := 0; i < len(words); i++ {
+		word := strings.ToLower(words[i])
+		switch word {
+		case "bold":
+			rs.attrs |= tcell.AttrBold
+		case "dim":
+			rs.attrs |= tcell.AttrDim
+		case "italic":
+			rs.attrs |= tcell.AttrItalic
+		case "underline":
+			rs.attrs |= tcell.AttrUnderline
+		case "reverse":
+			rs.attrs |= tcell.AttrReverse
+		case "on":
+			if i+1 == len(words) {
+				return rs, fmt.Errorf("missing background color in %q", spec)
+			}
+			i++
+			c := tcell.GetColor(strings.ToLower(words[i]))
+			if c == tcell.ColorDefault {
+				return rs, fmt.Errorf("unknown color %q", words[i])
+			}
+			rs.bg = c
+		default:
+			c := tcell.GetColor(word)
+			if c == tcell.ColorDefault {
+				return rs, fmt.Errorf("unknown color %q", words[i])
+			}
+			rs.fg, rs.basic = c, c
+		}
+	}
+	return rs, nil
+}
+
+// applyTheme sets the styles from a theme and the config file's overrides,
+// using only the colors a terminal with the given number of colors has.
+// Terminals without color get the monochrome theme's attributes, with the
+// overrides' attributes on top. Colors beyond 256 are matched to the
+// palette by tcell itself.
+func applyTheme(name string, overrides map[string]string, colors int) error {
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (choose from %s)", name, strings.Join(themeNames(), ", "))
+	}
+	if colors < 8 {
+		// Color themes tell roles apart by color, which is lost here
+		t = themes["monochrome"]
+	}
+
+	roles := theme{}
+	for role, rs := range t {
+		roles[role] = rs
+	}
+	for role, spec := range overrides {
+		rs, err := parseStyleSpec(spec)
+		if err != nil {
+			return fmt.Errorf("error in color %s: %v", role, err)
+		}
+		roles[role] = rs
+	}
+
+	for role, style := range styleRoles {
+		rs := roles[role]
+		s := tcell.StyleDefault.Attributes(rs.attrs)
+		if colors >= 8 {
+			// Roles without a background share the default one, so themes
+			// that set it fill the screen evenly
+			bg := rs.bg
+			if bg == tcell.ColorDefault {
+				bg = roles["default"].bg
+			}
+			s = s.Background(bg).Foreground(rs.basic)
+		}
+		if colors >= 256 {
+			s = s.Foreground(rs.fg)
+		}
+		*style = s
+	}
+	return nil
+}
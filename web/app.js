@@ -0,0 +1,182 @@
+// The web app for flash serve. Pages are chosen by the URL fragment:
+// #/ lists the decks, #/deck/NAME shows a deck's cards and #/review/NAME
+// reviews its due cards.
+
+const main = document.getElementById("main");
+const errorBox = document.getElementById("error");
+
+async function api(path, body, headers) {
+  const options = body === undefined ? {} : {
+    method: "POST",
+    headers: { "Content-Type": "application/json", ...headers },
+    body: JSON.stringify(body),
+  };
+  const res = await fetch("api/" + path, options);
+  const data = await res.json();
+  if (!res.ok) {
+    throw new Error(data.error || res.statusText);
+  }
+  return data;
+}
+
+// el builds an element with attributes and children, which may be strings.
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key.startsWith("on")) {
+      node.addEventListener(key.slice(2), value);
+    } else {
+      node.setAttribute(key, value);
+    }
+  }
+  node.append(...children.filter((child) => child !== null));
+  return node;
+}
+
+function show(...nodes) {
+  errorBox.hidden = true;
+  main.replaceChildren(...nodes);
+}
+
+function showError(err) {
+  errorBox.textContent = err.message;
+  errorBox.hidden = false;
+}
+
+function deckPath(name) {
+  return "decks/" + encodeURIComponent(name);
+}
+
+async function showDecks() {
+  const decks = await api("decks");
+  if (decks.length === 0) {
+    show(el("p", {}, "No .flsh files found."));
+    return;
+  }
+  show(...decks.map((deck) =>
+    el("div", { class: "deck" },
+      el("a", { class: "title", href: "#/deck/" + encodeURIComponent(deck.name) }, deck.title || deck.name),
+      el("span", { class: "muted" }, `${deck.due} due / ${deck.cards}`),
+      el("button", { onclick: () => { location.hash = "#/review/" + encodeURIComponent(deck.name); } }, "Review"))));
+}
+
+async function showDeck(name) {
+  const { deck, cards } = await api(deckPath(name));
+  const card = (c) => {
+    const state = [];
+    if (c.suspended) state.push("suspended");
+    if (c.buried_until) state.push("buried until " + c.buried_until);
+    if (c.flagged) state.push("flagged");
+    if (c.leech) state.push("leech");
+    state.push(...c.tags);
+    return el("div", { class: "card" },
+      el("div", { class: "label" }, `${c.number}.`),
+      el("div", { class: "text" }, c.front),
+      el("div", { class: "text" }, c.back),
+      state.length ? el("div", { class: "muted" }, state.join(", ")) : null);
+  };
+  show(
+    el("h2", {}, deck.title || deck.name),
+    el("p", {}, el("button", { onclick: () => { location.hash = "#/review/" + encodeURIComponent(name); } },
+      `Review ${deck.due} due cards`)),
+    ...cards.map(card));
+}
+
+// review shows the due cards one at a time and records each grade as it is
+// given, then adds the score to the deck's stats.
+async function review(name) {
+  const cards = await api(deckPath(name) + "/due");
+  let index = 0;
+  let correct = 0;
+  let total = 0;
+  let revealed = false;
+  let grading = false; // Ignore presses while a grade is being saved
+
+  const finish = async () => {
+    document.onkeydown = null;
+    if (total > 0) {
+      await api(deckPath(name) + "/sessions", { correct, total });
+    }
+    show(
+      el("h2", {}, total > 0 ? `${correct}/${total} correct` : "No cards to review"),
+      el("p", {}, el("a", { href: "#/" }, "Back to the decks")));
+  };
+
+  const grade = async (isCorrect) => {
+    if (grading) return;
+    grading = true;
+    let result;
+    try {
+      // If-Match keeps a card changed elsewhere since /due from taking the grade
+      result = await api(`${deckPath(name)}/cards/${cards[index].number}/grade`, { correct: isCorrect },
+        { "If-Match": cards[index].etag });
+    } finally {
+      grading = false;
+    }
+    total++;
+    if (isCorrect) correct++;
+    if (result.became_leech) {
+      alert(`Card ${result.card.number} has been failed ${result.card.reviews.filter((r) => r.endsWith("N")).length} times and is now a leech. Consider rewriting it.`);
+    }
+    index++;
+    revealed = false;
+    draw();
+  };
+
+  const draw = () => {
+    if (index >= cards.length) {
+      finish().catch(showError);
+      return;
+    }
+    const card = cards[index];
+    const nodes = [
+      el("p", { class: "muted" }, `Card ${index + 1} of ${cards.length}`),
+      el("div", { class: "label" }, "Front"),
+      el("div", { class: "text" }, card.front),
+    ];
+    if (revealed) {
+      nodes.push(
+        el("div", { class: "label" }, "Back"),
+        el("div", { class: "text" }, card.back),
+        el("div", { class: "buttons" },
+          el("button", { class: "correct", onclick: () => grade(true).catch(showError) }, "✓ Yes (y)"),
+          el("button", { class: "wrong", onclick: () => grade(false).catch(showError) }, "✗ No (n)")));
+    } else {
+      nodes.push(el("div", { class: "buttons" },
+        el("button", { onclick: () => { revealed = true; draw(); } }, "Show the back (space)")));
+    }
+    nodes.push(el("p", {}, el("button", { onclick: () => finish().catch(showError) }, "Stop")));
+    show(...nodes);
+  };
+
+  document.onkeydown = (ev) => {
+    if (!revealed && (ev.key === " " || ev.key === "Enter")) {
+      revealed = true;
+      draw();
+    } else if (revealed && (ev.key === "y" || ev.key === "n")) {
+      grade(ev.key === "y").catch(showError);
+    } else {
+      return;
+    }
+    ev.preventDefault();
+  };
+  draw();
+}
+
+function route() {
+  document.onkeydown = null;
+  const [, page, name] = location.hash.split("/");
+  const deck = name ? decodeURIComponent(name) : "";
+  let shown;
+  if (page === "deck" && deck) {
+    shown = showDeck(deck);
+  } else if (page === "review" && deck) {
+    shown = review(deck);
+  } else {
+    shown = showDecks();
+  }
+  shown.catch(showError);
+}
+
+window.addEventListener("hashchange", route);
+route();